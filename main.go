@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"os"
 	"strconv"
@@ -22,9 +23,11 @@ func NewTaskServer() *taskServer {
 
 func (ts *taskServer) createTaskHandler(c *gin.Context) {
 	type RequestTask struct {
-		Text string    `json:"text"`
-		Tags []string  `json:"tags"`
-		Due  time.Time `json:"due"`
+		Text     string    `json:"text"`
+		Notes    string    `json:"notes"`
+		Tags     []string  `json:"tags"`
+		Due      time.Time `json:"due"`
+		Priority int       `json:"priority"`
 	}
 
 	var rt RequestTask
@@ -33,33 +36,51 @@ func (ts *taskServer) createTaskHandler(c *gin.Context) {
 		return
 	}
 
-	id := ts.store.CreateTask(rt.Text, rt.Tags, rt.Due)
+	id, err := ts.store.CreateTaskFromInput(taskstore.TaskInput{
+		Text:     rt.Text,
+		Notes:    rt.Notes,
+		Tags:     rt.Tags,
+		Due:      rt.Due,
+		Priority: rt.Priority,
+	})
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"Id": id})
 }
 
-func (ts *taskServer) getAllTasksHandler(c *gin.Context) {
-	allTasks := ts.store.GetAllTasks()
-	c.JSON(http.StatusOK, allTasks)
+func getAllTasksHandler(store taskstore.TaskReader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allTasks := store.GetAllTasks()
+		c.JSON(http.StatusOK, allTasks)
+	}
 }
 
 func (ts *taskServer) deleteAllTasksHandler(c *gin.Context) {
 	ts.store.DeleteAllTasks()
 }
 
-func (ts *taskServer) getTaskHandler(c *gin.Context) {
-	id, err := strconv.Atoi(c.Params.ByName("id"))
-	if err != nil {
-		c.String(http.StatusBadRequest, err.Error())
-		return
-	}
-
-	task, err := ts.store.GetTask(id)
-	if err != nil {
-		c.String(http.StatusNotFound, err.Error())
-		return
+func getTaskHandler(store taskstore.TaskReader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Params.ByName("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		task, err := store.GetTask(id)
+		if errors.Is(err, taskstore.ErrTaskNotFound) {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		} else if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, task)
 	}
-
-	c.JSON(http.StatusOK, task)
 }
 
 func (ts *taskServer) deleteTaskHandler(c *gin.Context) {
@@ -69,42 +90,49 @@ func (ts *taskServer) deleteTaskHandler(c *gin.Context) {
 		return
 	}
 
-	if err = ts.store.DeleteTask(id); err != nil {
+	err = ts.store.DeleteTask(id)
+	if errors.Is(err, taskstore.ErrTaskNotFound) {
 		c.String(http.StatusNotFound, err.Error())
+	} else if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
 	}
 }
 
-func (ts *taskServer) tagHandler(c *gin.Context) {
-	tag := c.Params.ByName("tag")
-	tasks := ts.store.GetTasksByTag(tag)
-	c.JSON(http.StatusOK, tasks)
-}
-
-func (ts *taskServer) dueHandler(c *gin.Context) {
-	badRequestError := func() {
-		c.String(http.StatusBadRequest, "expect /due/<year>/<month>/<day>, got %v", c.FullPath())
-	}
-
-	year, err := strconv.Atoi(c.Params.ByName("year"))
-	if err != nil {
-		badRequestError()
-		return
-	}
-
-	month, err := strconv.Atoi(c.Params.ByName("month"))
-	if err != nil || month < int(time.January) || month > int(time.December) {
-		badRequestError()
-		return
+func tagHandler(store taskstore.TaskReader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag := c.Params.ByName("tag")
+		tasks := store.GetTasksByTag(tag)
+		c.JSON(http.StatusOK, tasks)
 	}
+}
 
-	day, err := strconv.Atoi(c.Params.ByName("day"))
-	if err != nil {
-		badRequestError()
-		return
+func dueHandler(store taskstore.TaskReader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		badRequestError := func() {
+			c.String(http.StatusBadRequest, "expect /due/<year>/<month>/<day>, got %v", c.FullPath())
+		}
+
+		year, err := strconv.Atoi(c.Params.ByName("year"))
+		if err != nil {
+			badRequestError()
+			return
+		}
+
+		month, err := strconv.Atoi(c.Params.ByName("month"))
+		if err != nil || month < int(time.January) || month > int(time.December) {
+			badRequestError()
+			return
+		}
+
+		day, err := strconv.Atoi(c.Params.ByName("day"))
+		if err != nil {
+			badRequestError()
+			return
+		}
+
+		tasks := store.GetTasksByDueDate(year, time.Month(month), day)
+		c.JSON(http.StatusOK, tasks)
 	}
-
-	tasks := ts.store.GetTasksByDueDate(year, time.Month(month), day)
-	c.JSON(http.StatusOK, tasks)
 }
 
 func main() {
@@ -113,12 +141,14 @@ func main() {
 	server := NewTaskServer()
 
 	router.POST("/task/", server.createTaskHandler)
-	router.GET("/task/", server.getAllTasksHandler)
 	router.DELETE("/task/", server.deleteAllTasksHandler)
-	router.GET("/task/:id", server.getTaskHandler)
 	router.DELETE("/task/:id", server.deleteTaskHandler)
-	router.GET("/tag/:tag", server.tagHandler)
-	router.GET("/due/:year/:month/:day", server.dueHandler)
+
+	// The read-only routes only get a TaskReader, so they cannot change the store.
+	router.GET("/task/", getAllTasksHandler(server.store))
+	router.GET("/task/:id", getTaskHandler(server.store))
+	router.GET("/tag/:tag", tagHandler(server.store))
+	router.GET("/due/:year/:month/:day", dueHandler(server.store))
 
 	router.Run("localhost:" + os.Getenv("SERVERPORT"))
 
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ali-Afifi/REST-api-go/pkg/taskstore"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve sends a GET request for path to handler, routed as route, and returns the response.
+func serve(route, path string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(route, handler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestReadHandlersTakeTaskReader(t *testing.T) {
+	store := taskstore.New()
+	id, err := store.CreateTask("task", []string{"home"}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Handing the handlers a TaskReader, not the store, is the point of the test.
+	var reader taskstore.TaskReader = store
+
+	w := serve("/task/:id", "/task/0", getTaskHandler(reader))
+	var task taskstore.Task
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &task) != nil || task.Id != id {
+		t.Errorf("GET /task/0 answered %d %s, want the task", w.Code, w.Body)
+	}
+
+	if w := serve("/task/:id", "/task/9", getTaskHandler(reader)); w.Code != http.StatusNotFound {
+		t.Errorf("GET /task/9 answered %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	w = serve("/tag/:tag", "/tag/home", tagHandler(reader))
+	var tasks []taskstore.Task
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &tasks) != nil || len(tasks) != 1 {
+		t.Errorf("GET /tag/home answered %d %s, want one task", w.Code, w.Body)
+	}
+
+	if w := serve("/due/:year/:month/:day", "/due/2023/13/1", dueHandler(reader)); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /due/2023/13/1 answered %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
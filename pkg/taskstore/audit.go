@@ -0,0 +1,95 @@
+package taskstore
+
+import "time"
+
+// AuditEntry records one change made to the store. Before is nil for EventCreated and After
+// is nil for EventDeleted. For EventReset, which replaces the whole content of the store,
+// TaskId is -1 and both are nil.
+type AuditEntry struct {
+	Op     EventType `json:"op"`
+	TaskId int       `json:"taskId"`
+	Time   time.Time `json:"time"`
+	Before *Task     `json:"before,omitempty"`
+	After  *Task     `json:"after,omitempty"`
+}
+
+// SetAuditLogSize makes the store keep a log of the last n changes made to it, which can be
+// read with GetTaskHistory. Once the log is full, every new entry evicts the oldest one. A
+// value of 0, the default, disables the log and drops the entries recorded so far.
+func (ts *TaskStore) SetAuditLogSize(n int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if n <= 0 {
+		ts.audit = nil
+		ts.auditStart = 0
+		ts.auditSize = 0
+		return
+	}
+
+	entries := ts.auditEntries()
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+
+	ts.audit = make([]AuditEntry, len(entries), n)
+	copy(ts.audit, entries)
+	ts.auditStart = 0
+	ts.auditSize = n
+}
+
+// GetTaskHistory returns the entries of the audit log about the task with the given id,
+// oldest first, see SetAuditLogSize. The EventReset entries are returned as well, since the
+// task may have been replaced by a different one with the same id at that point.
+func (ts *TaskStore) GetTaskHistory(id int) []AuditEntry {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	history := []AuditEntry{}
+
+	for _, entry := range ts.auditEntries() {
+		if entry.TaskId != id && entry.Op != EventReset {
+			continue
+		}
+
+		if entry.Before != nil {
+			before := copyTask(*entry.Before)
+			entry.Before = &before
+		}
+		if entry.After != nil {
+			after := copyTask(*entry.After)
+			entry.After = &after
+		}
+
+		history = append(history, entry)
+	}
+
+	return history
+}
+
+// record appends an entry to the audit log, if enabled, evicting the oldest one if the log
+// is full, the caller must hold the lock. The store never edits the tags of a stored task
+// in place, so before and after are kept as is.
+func (ts *TaskStore) record(op EventType, taskId int, before, after *Task) {
+	if ts.auditSize == 0 {
+		return
+	}
+
+	entry := AuditEntry{Op: op, TaskId: taskId, Time: ts.now(), Before: before, After: after}
+
+	if len(ts.audit) < ts.auditSize {
+		ts.audit = append(ts.audit, entry)
+		return
+	}
+
+	ts.audit[ts.auditStart] = entry
+	ts.auditStart = (ts.auditStart + 1) % ts.auditSize
+}
+
+// auditEntries returns the entries of the audit log, oldest first, the caller must hold the
+// lock.
+func (ts *TaskStore) auditEntries() []AuditEntry {
+	entries := make([]AuditEntry, 0, len(ts.audit))
+	entries = append(entries, ts.audit[ts.auditStart:]...)
+	return append(entries, ts.audit[:ts.auditStart]...)
+}
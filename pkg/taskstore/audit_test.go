@@ -0,0 +1,96 @@
+package taskstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// historyTexts returns the text after every change in history, or "-" for entries without
+// a task after the change.
+func historyTexts(history []AuditEntry) string {
+	texts := make([]string, len(history))
+	for i, entry := range history {
+		texts[i] = "-"
+		if entry.After != nil {
+			texts[i] = entry.After.Text
+		}
+	}
+	return fmt.Sprint(texts)
+}
+
+func TestAuditLogEvictsOldest(t *testing.T) {
+	ts := New()
+	ts.SetAuditLogSize(3)
+	id := mustCreate(t, ts, "v0", nil, time.Time{})
+
+	for i := 1; i <= 5; i++ {
+		ts.UpdateTask(id, fmt.Sprintf("v%d", i), nil, time.Time{})
+	}
+	if got := historyTexts(ts.GetTaskHistory(id)); got != "[v3 v4 v5]" {
+		t.Errorf("history of a full log is %s, want [v3 v4 v5]", got)
+	}
+
+	// Shrinking keeps the newest entries, and the log keeps wrapping around afterwards.
+	ts.SetAuditLogSize(2)
+	if got := historyTexts(ts.GetTaskHistory(id)); got != "[v4 v5]" {
+		t.Errorf("history after shrinking is %s, want [v4 v5]", got)
+	}
+	ts.UpdateTask(id, "v6", nil, time.Time{})
+	ts.UpdateTask(id, "v7", nil, time.Time{})
+	ts.UpdateTask(id, "v8", nil, time.Time{})
+	if got := historyTexts(ts.GetTaskHistory(id)); got != "[v7 v8]" {
+		t.Errorf("history after wrapping around is %s, want [v7 v8]", got)
+	}
+
+	// Growing keeps every entry and fills the new room before evicting.
+	ts.SetAuditLogSize(4)
+	ts.UpdateTask(id, "v9", nil, time.Time{})
+	ts.DeleteTask(id)
+	if got := historyTexts(ts.GetTaskHistory(id)); got != "[v7 v8 v9 -]" {
+		t.Errorf("history after growing is %s, want [v7 v8 v9 -]", got)
+	}
+	other := mustCreate(t, ts, "other", nil, time.Time{})
+	if got := historyTexts(ts.GetTaskHistory(id)); got != "[v8 v9 -]" {
+		t.Errorf("history after evicting for another task is %s, want [v8 v9 -]", got)
+	}
+	if got := historyTexts(ts.GetTaskHistory(other)); got != "[other]" {
+		t.Errorf("history of the other task is %s, want [other]", got)
+	}
+
+	ts.SetAuditLogSize(0)
+	ts.UpdateTask(other, "edited", nil, time.Time{})
+	if history := ts.GetTaskHistory(other); len(history) != 0 {
+		t.Errorf("disabled log returned %d entries", len(history))
+	}
+}
+
+func TestAuditLogEntries(t *testing.T) {
+	ts := New()
+	ts.SetAuditLogSize(10)
+	id := mustCreate(t, ts, "task", []string{"a"}, time.Time{})
+	ts.AddTagToTask(id, "b")
+	ts.Restore(ts.Snapshot())
+
+	history := ts.GetTaskHistory(id)
+	if len(history) != 3 {
+		t.Fatalf("got %d entries, want 3", len(history))
+	}
+
+	created, updated, reset := history[0], history[1], history[2]
+	if created.Op != EventCreated || created.Before != nil || created.After == nil {
+		t.Errorf("created entry is %+v", created)
+	}
+	if updated.Op != EventUpdated || fmt.Sprint(updated.Before.Tags) != "[a]" || fmt.Sprint(updated.After.Tags) != "[a b]" {
+		t.Errorf("updated entry is %+v", updated)
+	}
+	if reset.Op != EventReset || reset.TaskId != -1 {
+		t.Errorf("reset entry is %+v", reset)
+	}
+
+	// The entries are copies.
+	updated.After.Tags[0] = "changed"
+	if got := ts.GetTaskHistory(id)[1].After.Tags[0]; got != "a" {
+		t.Errorf("changing a returned entry changed the log to %q", got)
+	}
+}
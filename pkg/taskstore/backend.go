@@ -0,0 +1,89 @@
+package taskstore
+
+// Backend holds the tasks of a store, such as in memory, which is the default, or in a
+// database table. The store reads and writes every task through its backend and only keeps
+// its indexes in memory, rebuilt from All when the store is created. Get and All are called
+// while the store is locked for reading, possibly by several goroutines at once, and Put and
+// Delete while it is locked for writing, so a backend used by a single store need only be
+// safe for concurrent reads. The store never changes the tasks it gives to Put or gets from
+// Get and All, so a backend may keep and return them as is.
+type Backend interface {
+	// Get returns the task with the given id and reports whether it exists.
+	Get(id int) (Task, bool)
+	// Put adds task, replacing the task with the same id if any.
+	Put(task Task)
+	// Delete deletes the task with the given id and reports whether it existed.
+	Delete(id int) bool
+	// All returns all the tasks, in any order.
+	All() []Task
+}
+
+// NewWithBackend creates a new store holding its tasks in backend, starting with the tasks
+// already there. The next id is set past the largest id in backend.
+func NewWithBackend(backend Backend) *TaskStore {
+	ts := New()
+	ts.backend = backend
+
+	tasks := backend.All()
+	ts.count = len(tasks)
+
+	for _, task := range tasks {
+		if task.Id >= ts.nextId {
+			ts.nextId = task.Id + 1
+		}
+	}
+
+	ts.reindex()
+
+	return ts
+}
+
+// memoryBackend is a Backend keeping the tasks in a map.
+type memoryBackend struct {
+	tasks map[int]Task
+}
+
+// NewMemoryBackend returns a Backend keeping the tasks in memory, as the backend of a store
+// created with New does, which is mostly useful to hand the tasks of a store over to a new
+// one in tests. Put stores a copy of its task, but the tasks returned by Get and All share
+// their tags with the backend and must not be changed.
+func NewMemoryBackend() Backend {
+	return newMemoryBackend(0)
+}
+
+// newMemoryBackend returns a memoryBackend with room for about hint tasks.
+func newMemoryBackend(hint int) *memoryBackend {
+	return &memoryBackend{tasks: make(map[int]Task, hint)}
+}
+
+func (b *memoryBackend) Get(id int) (Task, bool) {
+	task, ok := b.tasks[id]
+	return task, ok
+}
+
+func (b *memoryBackend) Put(task Task) {
+	b.tasks[task.Id] = copyTask(task)
+}
+
+func (b *memoryBackend) Delete(id int) bool {
+	_, ok := b.tasks[id]
+	delete(b.tasks, id)
+	return ok
+}
+
+func (b *memoryBackend) All() []Task {
+	tasks := make([]Task, 0, len(b.tasks))
+
+	for _, task := range b.tasks {
+		tasks = append(tasks, task)
+	}
+
+	return tasks
+}
+
+// task returns the task with the given id, or the zero Task if there is none, the caller
+// must hold the lock.
+func (ts *TaskStore) task(id int) Task {
+	task, _ := ts.backend.Get(id)
+	return task
+}
@@ -0,0 +1,164 @@
+package taskstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// countingBackend is a Backend counting the calls made to another one.
+type countingBackend struct {
+	Backend
+	gets, puts, deletes, alls int
+}
+
+func (b *countingBackend) Get(id int) (Task, bool) {
+	b.gets++
+	return b.Backend.Get(id)
+}
+
+func (b *countingBackend) Put(task Task) {
+	b.puts++
+	b.Backend.Put(task)
+}
+
+func (b *countingBackend) Delete(id int) bool {
+	b.deletes++
+	return b.Backend.Delete(id)
+}
+
+func (b *countingBackend) All() []Task {
+	b.alls++
+	return b.Backend.All()
+}
+
+// reset sets all the counts back to zero.
+func (b *countingBackend) reset() {
+	b.gets, b.puts, b.deletes, b.alls = 0, 0, 0, 0
+}
+
+// backendTasks returns the tasks of backend keyed by id.
+func backendTasks(backend Backend) map[int]Task {
+	tasks := make(map[int]Task)
+	for _, task := range backend.All() {
+		tasks[task.Id] = task
+	}
+	return tasks
+}
+
+func TestStoreBehavesIdenticallyWithBackend(t *testing.T) {
+	clock := func() time.Time { return date(2023, time.May, 1) }
+	due := date(2023, time.May, 2)
+
+	plain := New()
+	backend := NewMemoryBackend()
+	backed := NewWithBackend(backend)
+
+	for _, ts := range []*TaskStore{plain, backed} {
+		ts.SetClock(clock)
+
+		a := mustCreate(t, ts, "a", []string{"x"}, due)
+		b := mustCreate(t, ts, "b", []string{"y"}, time.Time{})
+		c := mustCreate(t, ts, "c", []string{"x", "y"}, due)
+		ts.AddTagToTask(a, "z")
+		ts.SetTaskDone(b, true)
+		ts.RenameTag("y", "w")
+		ts.DeleteTask(c)
+		ts.ArchiveTask(b)
+		ts.CompactIds()
+		mustCreate(t, ts, "d", nil, due)
+	}
+
+	if got, want := backed.Snapshot(), plain.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("store with a backend holds %+v, want %+v", got, want)
+	}
+	if got, want := backed.GetTasksByTag("x"), plain.GetTasksByTag("x"); !reflect.DeepEqual(got, want) {
+		t.Errorf("store with a backend found %+v for a tag, want %+v", got, want)
+	}
+
+	if got, want := backendTasks(backend), backed.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("backend holds %+v, want %+v", got, want)
+	}
+
+	backed.DeleteAllTasks()
+	if n := len(backend.All()); n != 0 {
+		t.Errorf("backend holds %d tasks after DeleteAllTasks, want 0", n)
+	}
+}
+
+func TestNewWithBackendLoadsTasks(t *testing.T) {
+	backend := NewMemoryBackend()
+	first := NewWithBackend(backend)
+	mustCreate(t, first, "a", []string{"x"}, time.Time{})
+	b := mustCreate(t, first, "b", nil, time.Time{})
+
+	second := NewWithBackend(backend)
+
+	if !reflect.DeepEqual(second.Snapshot(), first.Snapshot()) {
+		t.Errorf("new store holds %+v, want %+v", second.Snapshot(), first.Snapshot())
+	}
+	checkIds(t, second.GetTasksByTag("x"), 0)
+
+	if id := mustCreate(t, second, "c", nil, time.Time{}); id != b+1 {
+		t.Errorf("new store created the id %d, want %d", id, b+1)
+	}
+}
+
+func TestReadsGoThroughBackend(t *testing.T) {
+	backend := &countingBackend{Backend: NewMemoryBackend()}
+	ts := NewWithBackend(backend)
+	id := mustCreate(t, ts, "task", []string{"x"}, time.Time{})
+
+	backend.reset()
+	if _, err := ts.GetTask(id); err != nil {
+		t.Fatal(err)
+	}
+	if backend.gets != 1 {
+		t.Errorf("GetTask made %d Get calls, want 1", backend.gets)
+	}
+
+	checkIds(t, ts.GetAllTasks(), id)
+	if backend.alls != 1 {
+		t.Errorf("GetAllTasks made %d All calls, want 1", backend.alls)
+	}
+
+	// Changing a task the backend holds is seen by the store, only the indexes are its own.
+	backend.Backend.Put(Task{Id: id, Text: "changed", Tags: []string{"x"}})
+	if task, _ := ts.GetTask(id); task.Text != "changed" {
+		t.Errorf("GetTask returned %q, want the text held by the backend", task.Text)
+	}
+}
+
+func TestBulkChangesWriteOnlyChangedTasks(t *testing.T) {
+	backend := &countingBackend{Backend: NewMemoryBackend()}
+	ts := NewWithBackend(backend)
+	for i := 0; i < 5; i++ {
+		mustCreate(t, ts, "task", nil, time.Time{})
+	}
+	snapshot := ts.Snapshot()
+
+	ts.UpdateTask(2, "changed", nil, time.Time{})
+	mustCreate(t, ts, "added", nil, time.Time{})
+
+	backend.reset()
+	ts.Restore(snapshot)
+	if backend.puts != 1 || backend.deletes != 1 {
+		t.Errorf("Restore made %d Put and %d Delete calls, want 1 and 1", backend.puts, backend.deletes)
+	}
+	if !reflect.DeepEqual(backendTasks(backend), snapshot) {
+		t.Errorf("backend holds %+v after Restore, want %+v", backendTasks(backend), snapshot)
+	}
+
+	// The ids are already compact, so nothing moves.
+	backend.reset()
+	ts.CompactIds()
+	if backend.puts != 0 || backend.deletes != 0 {
+		t.Errorf("CompactIds of compact ids made %d Put and %d Delete calls, want none", backend.puts, backend.deletes)
+	}
+
+	backend.reset()
+	ts.DeleteAllTasks()
+	if backend.puts != 0 || backend.deletes != 5 {
+		t.Errorf("DeleteAllTasks made %d Put and %d Delete calls, want 0 and 5", backend.puts, backend.deletes)
+	}
+}
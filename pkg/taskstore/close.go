@@ -0,0 +1,30 @@
+package taskstore
+
+import "errors"
+
+// ErrStoreClosed is returned by the methods changing the store once it is closed.
+var ErrStoreClosed = errors.New("store closed")
+
+// Close closes the channels of all the subscribers and marks the store as closed. Reads
+// keep working on the tasks left in the store, but every method changing them returns
+// ErrStoreClosed, or does nothing if it cannot return an error, and Subscribe returns an
+// already closed channel. Closing a store more than once is safe and returns nil.
+func (ts *TaskStore) Close() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return nil
+	}
+	ts.closed = true
+
+	ts.subMu.Lock()
+	defer ts.subMu.Unlock()
+
+	for id, ch := range ts.subscribers {
+		delete(ts.subscribers, id)
+		close(ch)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package taskstore
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCloseEndsSubscribers(t *testing.T) {
+	ts := New()
+	before := runtime.NumGoroutine()
+
+	const subscribers = 10
+	done := make(chan struct{})
+	for i := 0; i < subscribers; i++ {
+		events, _ := ts.Subscribe()
+		go func() {
+			for range events {
+			}
+			done <- struct{}{}
+		}()
+	}
+
+	mustCreate(t, ts, "task", nil, time.Time{})
+	if err := ts.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < subscribers; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d subscribers returned after Close", i, subscribers)
+		}
+	}
+
+	// The goroutines may still be exiting after signaling done.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines running after Close, want at most %d", n, before)
+	}
+
+	if err := ts.Close(); err != nil {
+		t.Errorf("second Close returned %v, want nil", err)
+	}
+
+	events, unsubscribe := ts.Subscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("Subscribe after Close returned an open channel")
+	}
+}
+
+func TestReadsAfterClose(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "task", []string{"a"}, time.Time{})
+	ts.Close()
+
+	if task, err := ts.GetTask(id); err != nil || task.Text != "task" {
+		t.Errorf("GetTask after Close returned (%+v, %v), want the task", task, err)
+	}
+	checkIds(t, ts.GetAllTasks(), id)
+	checkIds(t, ts.GetTasksByTag("a"), id)
+	if n := ts.Count(); n != 1 {
+		t.Errorf("Count after Close is %d, want 1", n)
+	}
+}
+
+func TestMutationsAfterClose(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "task", []string{"a"}, time.Time{})
+	mustCreateWithKey(t, ts, "key", "keyed")
+	ts.Close()
+
+	text := "edited"
+	mutations := map[string]func() error{
+		"CreateTask": func() error {
+			_, err := ts.CreateTask("new", nil, time.Time{})
+			return err
+		},
+		"CreateTaskWithKey new key": func() error {
+			_, _, err := ts.CreateTaskWithKey("other", "new", nil, time.Time{})
+			return err
+		},
+		"CreateTaskWithKey known key": func() error {
+			_, _, err := ts.CreateTaskWithKey("key", "keyed", nil, time.Time{})
+			return err
+		},
+		"UpdateTask": func() error {
+			return ts.UpdateTask(id, "edited", nil, time.Time{})
+		},
+		"PatchTask": func() error {
+			_, err := ts.PatchTask(id, TaskUpdate{Text: &text})
+			return err
+		},
+		"AddTagToTask": func() error {
+			return ts.AddTagToTask(id, "b")
+		},
+		"RenameTag": func() error {
+			_, err := ts.RenameTag("a", "b")
+			return err
+		},
+		"DeleteTask": func() error {
+			return ts.DeleteTask(id)
+		},
+		"DeleteAllTasks": func() error {
+			return ts.DeleteAllTasks()
+		},
+		"ReplaceAll": func() error {
+			return ts.ReplaceAll(nil)
+		},
+	}
+
+	for name, mutate := range mutations {
+		if err := mutate(); !errors.Is(err, ErrStoreClosed) {
+			t.Errorf("%s after Close returned %v, want ErrStoreClosed", name, err)
+		}
+	}
+
+	if task, _ := ts.GetTask(id); task.Text != "task" || len(task.Tags) != 1 {
+		t.Errorf("task changed after Close: %+v", task)
+	}
+	if n := ts.Count(); n != 2 {
+		t.Errorf("Count after Close is %d, want 2", n)
+	}
+}
@@ -0,0 +1,54 @@
+package taskstore
+
+import (
+	"context"
+	"time"
+)
+
+// The methods in this file are variants of the store methods that first check whether ctx
+// is already done, in which case ctx.Err() is returned without touching the store.
+
+// GetTaskCtx is like GetTask but honours the cancellation of ctx.
+func (ts *TaskStore) GetTaskCtx(ctx context.Context, id int) (Task, error) {
+	if err := ctx.Err(); err != nil {
+		return Task{}, err
+	}
+
+	return ts.GetTask(id)
+}
+
+// GetAllTasksCtx is like GetAllTasks but honours the cancellation of ctx.
+func (ts *TaskStore) GetAllTasksCtx(ctx context.Context) ([]Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ts.GetAllTasks(), nil
+}
+
+// GetTasksByTagCtx is like GetTasksByTag but honours the cancellation of ctx.
+func (ts *TaskStore) GetTasksByTagCtx(ctx context.Context, tag string) ([]Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ts.GetTasksByTag(tag), nil
+}
+
+// GetTasksByDueDateCtx is like GetTasksByDueDate but honours the cancellation of ctx.
+func (ts *TaskStore) GetTasksByDueDateCtx(ctx context.Context, year int, month time.Month, day int) ([]Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ts.GetTasksByDueDate(year, month, day), nil
+}
+
+// SearchTasksCtx is like SearchTasks but honours the cancellation of ctx.
+func (ts *TaskStore) SearchTasksCtx(ctx context.Context, query string) ([]Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ts.SearchTasks(query), nil
+}
@@ -0,0 +1,94 @@
+package taskstore
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader lists the columns written by ExportCSV and expected by ImportCSV.
+var csvHeader = []string{"id", "text", "tags", "due"}
+
+// csvTagSeparator joins the tags of a task in the tags column.
+const csvTagSeparator = ";"
+
+// ExportCSV writes the tasks that are not archived to w as CSV, sorted by id, with a header
+// row followed by one row per task. Tags are joined with semicolons and due dates written
+// in RFC 3339 format, an empty cell meaning no due date.
+func (ts *TaskStore) ExportCSV(w io.Writer) error {
+	ts.mu.RLock()
+	tasks := ts.activeTasks()
+	ts.mu.RUnlock()
+
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		due := ""
+		if !task.Due.IsZero() {
+			due = task.Due.Format(time.RFC3339Nano)
+		}
+
+		record := []string{strconv.Itoa(task.Id), task.Text, strings.Join(task.Tags, csvTagSeparator), due}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportCSV creates a new store holding the tasks read from r, in the format written by
+// ExportCSV. The id column is ignored and the tasks are given fresh ids in the order they
+// are read.
+func ImportCSV(r io.Reader) (*TaskStore, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing csv header")
+	}
+
+	for i, column := range csvHeader {
+		if records[0][i] != column {
+			return nil, fmt.Errorf("unexpected csv header %v, want %v", records[0], csvHeader)
+		}
+	}
+
+	inputs := make([]TaskInput, 0, len(records)-1)
+
+	for i, record := range records[1:] {
+		input := TaskInput{Text: record[1]}
+
+		if record[2] != "" {
+			input.Tags = strings.Split(record[2], csvTagSeparator)
+		}
+
+		if record[3] != "" {
+			if input.Due, err = time.Parse(time.RFC3339Nano, record[3]); err != nil {
+				return nil, fmt.Errorf("row %d: %w", i+2, err)
+			}
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	ts := New()
+	if _, err := ts.CreateTasks(inputs); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
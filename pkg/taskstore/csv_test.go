@@ -0,0 +1,63 @@
+package taskstore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	due := time.Date(2023, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	ts := New()
+	mustCreate(t, ts, "buy milk, eggs", []string{"home", "shop"}, due)
+	mustCreate(t, ts, `say "hi"`, nil, time.Time{})
+	archived := mustCreate(t, ts, "archived", nil, time.Time{})
+	ts.ArchiveTask(archived)
+
+	var buf strings.Builder
+	if err := ts.ExportCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id,text,tags,due\n" +
+		"0,\"buy milk, eggs\",home;shop,2023-03-01T12:30:00Z\n" +
+		"1,\"say \"\"hi\"\"\",,\n"
+	if buf.String() != want {
+		t.Errorf("ExportCSV wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	imported, err := ImportCSV(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := imported.GetAllTasks()
+	checkIds(t, tasks, 0, 1)
+	if len(tasks) != 2 {
+		return
+	}
+	if task := tasks[0]; task.Text != "buy milk, eggs" || fmt.Sprint(task.Tags) != "[home shop]" || !task.Due.Equal(due) {
+		t.Errorf("first imported task is %+v", task)
+	}
+	if task := tasks[1]; task.Text != `say "hi"` || len(task.Tags) != 0 || !task.Due.IsZero() {
+		t.Errorf("second imported task is %+v", task)
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	inputs := map[string]string{
+		"empty":        "",
+		"wrong header": "id,text,labels,due\n",
+		"short row":    "id,text,tags,due\n0,task,\n",
+		"bad due":      "id,text,tags,due\n0,task,,tomorrow\n",
+		"empty text":   "id,text,tags,due\n0,,,\n",
+	}
+
+	for name, input := range inputs {
+		if _, err := ImportCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("ImportCSV of %s input succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package taskstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetDependencies makes the task with the given id depend on the tasks with the ids deps,
+// replacing its previous dependencies, an empty slice clears them. Duplicate ids are only
+// kept once. If a task does not exist, the task would depend on itself or the dependencies
+// would form a cycle, an error is returned and nothing is changed.
+func (ts *TaskStore) SetDependencies(id int, deps []int) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	unique := make([]int, 0, len(deps))
+	seen := make(map[int]bool, len(deps))
+
+	for _, dep := range deps {
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+
+		if dep == id {
+			return fmt.Errorf("task with id=%d cannot depend on itself", id)
+		}
+		if _, ok := ts.backend.Get(dep); !ok {
+			return fmt.Errorf("cannot depend on task with id=%d: %w", dep, taskNotFound(dep))
+		}
+
+		unique = append(unique, dep)
+	}
+
+	if cycle := ts.findCycle(id, unique); cycle != nil {
+		ids := make([]string, len(cycle))
+		for i, cycleId := range cycle {
+			ids[i] = fmt.Sprint(cycleId)
+		}
+		return fmt.Errorf("dependencies of task with id=%d would form the cycle %s", id, strings.Join(ids, " -> "))
+	}
+
+	task.DependsOn = unique
+	ts.putTask(task)
+
+	return nil
+}
+
+// GetBlockedTasks returns the tasks that depend on at least one task that is not done,
+// sorted by id. Dependencies on deleted tasks do not block.
+func (ts *TaskStore) GetBlockedTasks() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived {
+			continue
+		}
+
+		for _, dep := range task.DependsOn {
+			if depTask, ok := ts.backend.Get(dep); ok && !depTask.Done {
+				tasks = append(tasks, copyTask(task))
+				break
+			}
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// findCycle returns the ids along a cycle, starting and ending with id, that giving deps to
+// the task with the given id would create, or nil if there is none. It walks the
+// dependencies depth first from deps, the caller must hold the lock.
+func (ts *TaskStore) findCycle(id int, deps []int) []int {
+	visited := make(map[int]bool)
+	path := []int{id}
+
+	var visit func(current int) bool
+	visit = func(current int) bool {
+		if current == id {
+			path = append(path, current)
+			return true
+		}
+		if visited[current] {
+			return false
+		}
+		visited[current] = true
+
+		path = append(path, current)
+		for _, dep := range ts.task(current).DependsOn {
+			if visit(dep) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+
+		return false
+	}
+
+	for _, dep := range deps {
+		if visit(dep) {
+			return path
+		}
+	}
+
+	return nil
+}
+
+// remapDeps returns a copy of deps with every id replaced according to mapping, the ids
+// missing from mapping are dropped.
+func remapDeps(deps []int, mapping map[int]int) []int {
+	if deps == nil {
+		return nil
+	}
+
+	remapped := make([]int, 0, len(deps))
+
+	for _, dep := range deps {
+		if newId, ok := mapping[dep]; ok {
+			remapped = append(remapped, newId)
+		}
+	}
+
+	return remapped
+}
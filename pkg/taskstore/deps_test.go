@@ -0,0 +1,73 @@
+package taskstore
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSetDependenciesRejectsCycle(t *testing.T) {
+	ts := New()
+	a := mustCreate(t, ts, "a", nil, time.Time{})
+	b := mustCreate(t, ts, "b", nil, time.Time{})
+	c := mustCreate(t, ts, "c", nil, time.Time{})
+
+	if err := ts.SetDependencies(a, []int{b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.SetDependencies(b, []int{c}); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.mu.RLock()
+	cycle := ts.findCycle(c, []int{a})
+	ts.mu.RUnlock()
+	if want := []int{c, a, b, c}; fmt.Sprint(cycle) != fmt.Sprint(want) {
+		t.Errorf("findCycle returned %v, want %v", cycle, want)
+	}
+
+	if err := ts.SetDependencies(c, []int{a}); err == nil {
+		t.Error("SetDependencies closing a 3-task cycle succeeded")
+	}
+	if task, _ := ts.GetTask(c); len(task.DependsOn) != 0 {
+		t.Errorf("rejected dependencies were stored: %v", task.DependsOn)
+	}
+
+	if err := ts.SetDependencies(a, []int{a}); err == nil {
+		t.Error("SetDependencies on the task itself succeeded")
+	}
+	if err := ts.SetDependencies(a, []int{-1}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("SetDependencies on a missing task returned %v, want ErrTaskNotFound", err)
+	}
+
+	// A diamond shares dependencies without forming a cycle.
+	d := mustCreate(t, ts, "d", nil, time.Time{})
+	if err := ts.SetDependencies(d, []int{a, b, b}); err != nil {
+		t.Errorf("SetDependencies on a diamond: %v", err)
+	}
+	if task, _ := ts.GetTask(d); fmt.Sprint(task.DependsOn) != fmt.Sprint([]int{a, b}) {
+		t.Errorf("dependencies are %v, want %v", task.DependsOn, []int{a, b})
+	}
+}
+
+func TestGetBlockedTasks(t *testing.T) {
+	ts := New()
+	dep := mustCreate(t, ts, "dep", nil, time.Time{})
+	blocked := mustCreate(t, ts, "blocked", nil, time.Time{})
+	free := mustCreate(t, ts, "free", nil, time.Time{})
+	gone := mustCreate(t, ts, "gone", nil, time.Time{})
+	onGone := mustCreate(t, ts, "on gone", nil, time.Time{})
+
+	ts.SetDependencies(blocked, []int{dep})
+	ts.SetDependencies(onGone, []int{gone})
+	ts.DeleteTask(gone)
+
+	checkIds(t, ts.GetBlockedTasks(), blocked)
+
+	ts.SetTaskDone(dep, true)
+	checkIds(t, ts.GetBlockedTasks())
+
+	ts.SetDependencies(free, []int{blocked})
+	checkIds(t, ts.GetBlockedTasks(), free)
+}
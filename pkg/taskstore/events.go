@@ -0,0 +1,83 @@
+package taskstore
+
+import "sync"
+
+// EventType tells what kind of change a TaskEvent describes.
+type EventType string
+
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventDeleted EventType = "deleted"
+	// EventReset means the whole content of the store was replaced, by Restore, CompactIds
+	// or when loading it, subscribers should reload all the tasks.
+	EventReset EventType = "reset"
+)
+
+// TaskEvent describes a change to the store. TaskId is -1 for EventReset.
+type TaskEvent struct {
+	Type   EventType `json:"type"`
+	TaskId int       `json:"taskId"`
+}
+
+// subscriberBuffer is the number of events buffered for every subscriber.
+const subscriberBuffer = 64
+
+// Subscribe returns a channel receiving an event after every change to the store, and a
+// function that unsubscribes and closes the channel, which is safe to call more than once
+// and from any goroutine. Writers never wait on subscribers: the channel buffers a few
+// events and further events are dropped while the buffer is full.
+func (ts *TaskStore) Subscribe() (<-chan TaskEvent, func()) {
+	// Hold the read lock so that Close cannot run before the subscriber is added.
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	ts.subMu.Lock()
+	defer ts.subMu.Unlock()
+
+	if ts.closed {
+		ch := make(chan TaskEvent)
+		close(ch)
+		return ch, func() {}
+	}
+
+	if ts.subscribers == nil {
+		ts.subscribers = make(map[int]chan TaskEvent)
+	}
+
+	id := ts.nextSubId
+	ts.nextSubId++
+
+	ch := make(chan TaskEvent, subscriberBuffer)
+	ts.subscribers[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			ts.subMu.Lock()
+			defer ts.subMu.Unlock()
+
+			// Close may already have closed the channel.
+			if _, ok := ts.subscribers[id]; ok {
+				delete(ts.subscribers, id)
+				close(ch)
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// emit sends event to every subscriber without blocking, it is called once the change is
+// applied.
+func (ts *TaskStore) emit(eventType EventType, taskId int) {
+	ts.subMu.Lock()
+	defer ts.subMu.Unlock()
+
+	for _, ch := range ts.subscribers {
+		select {
+		case ch <- TaskEvent{Type: eventType, TaskId: taskId}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package taskstore
+
+import "expvar"
+
+// PublishExpvars publishes live numbers about the store with the expvar package, which
+// serves them at /debug/vars, under the names prefix followed by totalTasks, overdueTasks
+// and distinctTags. They are computed by Stats each time they are read, relative to the
+// current time of the store clock. As with expvar.Publish, it panics if one of the names
+// is already taken, so it must be called once per prefix.
+func (ts *TaskStore) PublishExpvars(prefix string) {
+	expvar.Publish(prefix+"totalTasks", expvar.Func(func() interface{} {
+		return ts.currentStats().TotalTasks
+	}))
+
+	expvar.Publish(prefix+"overdueTasks", expvar.Func(func() interface{} {
+		return ts.currentStats().OverdueCount
+	}))
+
+	expvar.Publish(prefix+"distinctTags", expvar.Func(func() interface{} {
+		return ts.currentStats().DistinctTags
+	}))
+}
+
+// currentStats returns the Stats of the store at the current time of its clock.
+func (ts *TaskStore) currentStats() StoreStats {
+	ts.mu.RLock()
+	now := ts.now()
+	ts.mu.RUnlock()
+
+	return ts.Stats(now)
+}
@@ -0,0 +1,40 @@
+package taskstore
+
+import (
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestPublishExpvars(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ts := New()
+	ts.SetClock(func() time.Time { return now })
+
+	// expvar names are global to the process, so the prefix is unique to this test.
+	const prefix = "TestPublishExpvars."
+	ts.PublishExpvars(prefix)
+
+	check := func(want map[string]string) {
+		t.Helper()
+		for name, value := range want {
+			v := expvar.Get(prefix + name)
+			if v == nil {
+				t.Fatalf("%s%s is not published", prefix, name)
+			}
+			if got := v.String(); got != value {
+				t.Errorf("%s%s = %s, want %s", prefix, name, got, value)
+			}
+		}
+	}
+
+	check(map[string]string{"totalTasks": "0", "overdueTasks": "0", "distinctTags": "0"})
+
+	mustCreate(t, ts, "overdue", []string{"a", "b"}, now.Add(-time.Hour))
+	mustCreate(t, ts, "upcoming", []string{"a"}, now.Add(time.Hour))
+	check(map[string]string{"totalTasks": "2", "overdueTasks": "1", "distinctTags": "2"})
+
+	// The values follow the store clock.
+	now = now.Add(2 * time.Hour)
+	check(map[string]string{"totalTasks": "2", "overdueTasks": "2", "distinctTags": "2"})
+}
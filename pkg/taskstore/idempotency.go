@@ -0,0 +1,129 @@
+package taskstore
+
+import "time"
+
+// idempotencyKey records the task created for a key given to CreateTaskWithKey.
+type idempotencyKey struct {
+	id      int
+	created time.Time
+}
+
+// CreateTaskWithKey is like CreateTask but remembers key, a repeated call with the same key
+// creates nothing and returns the id of the task created by the first call, with created
+// set to false. Keys are kept until ForgetKey is called or, if a TTL is set with SetKeyTTL,
+// until Maintenance finds them expired. A key is also forgotten when its task is deleted or
+// when the whole content of the store is replaced, by Restore, ReplaceAll or when loading
+// it, and follows its task when CompactIds renumbers it.
+func (ts *TaskStore) CreateTaskWithKey(key string, text string, tags []string, due time.Time) (id int, created bool, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0, false, ErrStoreClosed
+	}
+
+	if k, ok := ts.keys[key]; ok {
+		return k.id, false, nil
+	}
+
+	task, err := ts.createTask(TaskInput{Text: text, Tags: tags, Due: due})
+	if err != nil {
+		return 0, false, err
+	}
+
+	if ts.keys == nil {
+		ts.keys = make(map[string]idempotencyKey)
+		ts.keyOf = make(map[int]string)
+	}
+	ts.keys[key] = idempotencyKey{id: task.Id, created: ts.now()}
+	ts.keyOf[task.Id] = key
+
+	return task.Id, true, nil
+}
+
+// ForgetKey forgets an idempotency key given to CreateTaskWithKey, so it can be used again.
+func (ts *TaskStore) ForgetKey(key string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.forgetKey(key)
+}
+
+// SetKeyTTL makes Maintenance forget the idempotency keys given to CreateTaskWithKey more
+// than ttl ago. A value of 0, the default, keeps them until ForgetKey is called.
+func (ts *TaskStore) SetKeyTTL(ttl time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.keyTTL = ttl
+}
+
+// Maintenance releases the memory held by state that is no longer needed, it is meant to be
+// called periodically. It forgets the idempotency keys older than the TTL set with
+// SetKeyTTL, and shrinks the table of subscribers, which Go maps never do on their own
+// after many subscribers have unsubscribed.
+func (ts *TaskStore) Maintenance() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.keyTTL > 0 {
+		cutoff := ts.now().Add(-ts.keyTTL)
+
+		for key, k := range ts.keys {
+			if k.created.Before(cutoff) {
+				ts.forgetKey(key)
+			}
+		}
+	}
+
+	ts.subMu.Lock()
+	defer ts.subMu.Unlock()
+
+	subscribers := make(map[int]chan TaskEvent, len(ts.subscribers))
+	for id, ch := range ts.subscribers {
+		subscribers[id] = ch
+	}
+	ts.subscribers = subscribers
+}
+
+// forgetKey forgets an idempotency key, the caller must hold the lock.
+func (ts *TaskStore) forgetKey(key string) {
+	if k, ok := ts.keys[key]; ok {
+		delete(ts.keyOf, k.id)
+		delete(ts.keys, key)
+	}
+}
+
+// forgetTaskKey forgets the idempotency key of the task with the given id, if any, the
+// caller must hold the lock.
+func (ts *TaskStore) forgetTaskKey(id int) {
+	if key, ok := ts.keyOf[id]; ok {
+		delete(ts.keys, key)
+		delete(ts.keyOf, id)
+	}
+}
+
+// dropKeys forgets all the idempotency keys, as their ids may name other tasks once the
+// content of the store is replaced, the caller must hold the lock.
+func (ts *TaskStore) dropKeys() {
+	ts.keys = nil
+	ts.keyOf = nil
+}
+
+// remapKeys moves the idempotency keys to the new ids of their tasks, the caller must hold
+// the lock.
+func (ts *TaskStore) remapKeys(mapping map[int]int) {
+	if len(ts.keys) == 0 {
+		return
+	}
+
+	keyOf := make(map[int]string, len(ts.keyOf))
+
+	for key, k := range ts.keys {
+		k.id = mapping[k.id]
+		ts.keys[key] = k
+		keyOf[k.id] = key
+	}
+
+	ts.keyOf = keyOf
+}
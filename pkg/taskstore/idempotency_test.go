@@ -0,0 +1,154 @@
+package taskstore
+
+import (
+	"testing"
+	"time"
+)
+
+// mustCreateWithKey creates a task in ts with the idempotency key and fails the test if
+// that is not possible.
+func mustCreateWithKey(t *testing.T, ts *TaskStore, key, text string) (int, bool) {
+	t.Helper()
+
+	id, created, err := ts.CreateTaskWithKey(key, text, nil, time.Time{})
+	if err != nil {
+		t.Fatalf("CreateTaskWithKey(%q, %q): %v", key, text, err)
+	}
+	return id, created
+}
+
+func TestCreateTaskWithKeyRepeated(t *testing.T) {
+	ts := New()
+
+	id, created := mustCreateWithKey(t, ts, "key", "first")
+	if !created {
+		t.Fatal("first call did not create a task")
+	}
+
+	again, created := mustCreateWithKey(t, ts, "key", "second")
+	if created || again != id {
+		t.Errorf("repeated call returned (%d, %v), want (%d, false)", again, created, id)
+	}
+	if n := ts.Count(); n != 1 {
+		t.Errorf("store has %d tasks, want 1", n)
+	}
+
+	ts.ForgetKey("key")
+	if _, created := mustCreateWithKey(t, ts, "key", "third"); !created {
+		t.Error("call after ForgetKey did not create a task")
+	}
+}
+
+func TestCreateTaskWithKeyAfterDelete(t *testing.T) {
+	ts := New()
+	mustCreate(t, ts, "other", nil, time.Time{})
+
+	id, _ := mustCreateWithKey(t, ts, "key", "task")
+	if err := ts.DeleteTask(id); err != nil {
+		t.Fatal(err)
+	}
+
+	again, created := mustCreateWithKey(t, ts, "key", "task")
+	if !created || again == id || !ts.Exists(again) {
+		t.Errorf("call after delete returned (%d, %v), want a new task", again, created)
+	}
+}
+
+func TestCreateTaskWithKeyAfterCompactIds(t *testing.T) {
+	ts := New()
+	first := mustCreate(t, ts, "first", nil, time.Time{})
+	id, _ := mustCreateWithKey(t, ts, "key", "keyed")
+	ts.DeleteTask(first)
+
+	mapping := ts.CompactIds()
+
+	again, created := mustCreateWithKey(t, ts, "key", "keyed")
+	if created || again != mapping[id] {
+		t.Errorf("call after CompactIds returned (%d, %v), want (%d, false)", again, created, mapping[id])
+	}
+}
+
+func TestCreateTaskWithKeyAfterReplace(t *testing.T) {
+	replacements := map[string]func(ts *TaskStore){
+		"Restore": func(ts *TaskStore) {
+			ts.Restore(map[int]Task{0: {Text: "replacement"}})
+		},
+		"ReplaceAll": func(ts *TaskStore) {
+			ts.ReplaceAll([]Task{{Id: 0, Text: "replacement"}})
+		},
+	}
+
+	for name, replace := range replacements {
+		t.Run(name, func(t *testing.T) {
+			ts := New()
+			mustCreateWithKey(t, ts, "key", "keyed")
+
+			replace(ts)
+
+			if _, created := mustCreateWithKey(t, ts, "key", "keyed"); !created {
+				t.Error("the key survived the replacement of the store")
+			}
+		})
+	}
+}
+
+func TestMaintenanceForgetsExpiredKeys(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ts := New()
+	ts.SetClock(func() time.Time { return now })
+
+	ts.Maintenance()
+	old, _ := mustCreateWithKey(t, ts, "old", "old")
+
+	// Without a TTL, Maintenance keeps every key.
+	now = now.Add(24 * time.Hour)
+	ts.Maintenance()
+	if _, created := mustCreateWithKey(t, ts, "old", "old"); created {
+		t.Fatal("Maintenance without a TTL forgot a key")
+	}
+
+	ts.SetKeyTTL(time.Hour)
+	recent, _ := mustCreateWithKey(t, ts, "recent", "recent")
+
+	now = now.Add(30 * time.Minute)
+	ts.Maintenance()
+	if id, created := mustCreateWithKey(t, ts, "recent", "recent"); created || id != recent {
+		t.Errorf("key within the TTL returned (%d, %v), want (%d, false)", id, created, recent)
+	}
+	if id, created := mustCreateWithKey(t, ts, "old", "old"); !created || id == old {
+		t.Errorf("key past the TTL returned (%d, %v), want a new task", id, created)
+	}
+
+	now = now.Add(time.Hour)
+	ts.Maintenance()
+	if _, created := mustCreateWithKey(t, ts, "recent", "recent"); !created {
+		t.Error("key past the TTL was kept")
+	}
+
+	events, unsubscribe := ts.Subscribe()
+	unsubscribe()
+	ts.Maintenance()
+	if _, ok := <-events; ok {
+		t.Error("Maintenance reopened an unsubscribed channel")
+	}
+}
+
+func TestCreateTaskWithKeyAfterWithLock(t *testing.T) {
+	ts := New()
+	kept, _ := mustCreateWithKey(t, ts, "kept", "kept")
+	deleted, _ := mustCreateWithKey(t, ts, "deleted", "deleted")
+
+	ts.WithLock(func(tasks map[int]Task) {
+		task := tasks[kept]
+		task.Text = "edited"
+		tasks[kept] = task
+		delete(tasks, deleted)
+	})
+
+	if id, created := mustCreateWithKey(t, ts, "kept", "kept"); created || id != kept {
+		t.Errorf("key of a task changed by WithLock returned (%d, %v), want (%d, false)", id, created, kept)
+	}
+	if _, created := mustCreateWithKey(t, ts, "deleted", "deleted"); !created {
+		t.Error("key of a task deleted by WithLock survived")
+	}
+}
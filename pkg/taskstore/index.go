@@ -0,0 +1,177 @@
+package taskstore
+
+import (
+	"reflect"
+	"time"
+)
+
+// The store keeps indexes next to the backend so that lookups by UUID, tag and due date do
+// not need to scan every task, as well as the number of tasks, the number of archived tasks
+// and the view returned by SnapshotView. All writes to the backend must go through putTask,
+// removeTask or setTasks, which keep the indexes in sync. putTask and removeTask also record
+// the change in the audit log and notify the subscribers of it.
+
+// putTask adds task to the store, replacing the task with the same id if any, and returns
+// it with its version and modification time bumped, the caller must hold the lock.
+func (ts *TaskStore) putTask(task Task) Task {
+	eventType := EventCreated
+	old, ok := ts.backend.Get(task.Id)
+	if ok {
+		ts.unindexTask(old)
+		eventType = EventUpdated
+	} else {
+		ts.count++
+	}
+
+	task.Version = old.Version + 1
+	task.UpdatedAt = ts.now()
+
+	ts.backend.Put(task)
+	ts.indexTask(task)
+	ts.invalidateView()
+
+	var before *Task
+	if ok {
+		before = &old
+	}
+	ts.record(eventType, task.Id, before, &task)
+	ts.emit(eventType, task.Id)
+
+	return task
+}
+
+// removeTask deletes the task with the given id, which must exist, the caller must hold
+// the lock.
+func (ts *TaskStore) removeTask(id int) {
+	old := ts.task(id)
+	ts.unindexTask(old)
+	ts.backend.Delete(id)
+	ts.count--
+	ts.invalidateView()
+	ts.forgetTaskKey(id)
+
+	ts.record(EventDeleted, id, &old, nil)
+	ts.emit(EventDeleted, id)
+}
+
+// setTasks replaces all the tasks in the store with tasks, keyed by id, and rebuilds the
+// indexes, the caller must hold the lock. Only the tasks that were added, changed or
+// deleted are written to the backend. Unlike putTask, it leaves the versions and
+// modification times of the tasks as they are, and records and emits nothing.
+func (ts *TaskStore) setTasks(tasks map[int]Task) {
+	// A store decoded into its zero value has no backend yet.
+	if ts.backend == nil {
+		ts.backend = newMemoryBackend(len(tasks))
+	}
+
+	for _, old := range ts.backend.All() {
+		if _, ok := tasks[old.Id]; !ok {
+			ts.backend.Delete(old.Id)
+		}
+	}
+
+	for id, task := range tasks {
+		if old, ok := ts.backend.Get(id); !ok || !sameTask(old, task) {
+			ts.backend.Put(task)
+		}
+	}
+
+	ts.count = len(tasks)
+	ts.reindex()
+}
+
+// sameTask reports whether a and b have the same fields, nil and empty tags being the same.
+func sameTask(a, b Task) bool {
+	return reflect.DeepEqual(copyTask(a), copyTask(b))
+}
+
+// reindex rebuilds all the indexes from the tasks in the backend, the caller must hold the
+// lock.
+func (ts *TaskStore) reindex() {
+	ts.uids = make(map[string]int)
+	ts.tagIndex = make(map[string][]int)
+	ts.dueIndex = make(map[dayKey][]int)
+	ts.archived = 0
+
+	for _, task := range ts.backend.All() {
+		ts.indexTask(task)
+	}
+	ts.invalidateView()
+}
+
+// indexTask adds task to the indexes.
+func (ts *TaskStore) indexTask(task Task) {
+	if task.Uid != "" {
+		ts.uids[task.Uid] = task.Id
+	}
+
+	if task.Archived {
+		ts.archived++
+	}
+
+	for i, tag := range task.Tags {
+		if !hasTag(task.Tags[:i], tag) {
+			ts.tagIndex[tag] = append(ts.tagIndex[tag], task.Id)
+		}
+	}
+
+	if !task.Due.IsZero() {
+		key := dueDay(task.Due)
+		ts.dueIndex[key] = append(ts.dueIndex[key], task.Id)
+	}
+}
+
+// unindexTask removes task from the indexes.
+func (ts *TaskStore) unindexTask(task Task) {
+	if task.Uid != "" {
+		delete(ts.uids, task.Uid)
+	}
+
+	if task.Archived {
+		ts.archived--
+	}
+
+	for i, tag := range task.Tags {
+		if hasTag(task.Tags[:i], tag) {
+			continue
+		}
+
+		if ids := removeId(ts.tagIndex[tag], task.Id); len(ids) == 0 {
+			delete(ts.tagIndex, tag)
+		} else {
+			ts.tagIndex[tag] = ids
+		}
+	}
+
+	if !task.Due.IsZero() {
+		key := dueDay(task.Due)
+		if ids := removeId(ts.dueIndex[key], task.Id); len(ids) == 0 {
+			delete(ts.dueIndex, key)
+		} else {
+			ts.dueIndex[key] = ids
+		}
+	}
+}
+
+// dayKey identifies a calendar day in the due date index.
+type dayKey struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// dueDay returns the calendar day of due, in the location of due.
+func dueDay(due time.Time) dayKey {
+	y, m, d := due.Date()
+	return dayKey{year: y, month: m, day: d}
+}
+
+// removeId removes the first occurrence of id from ids, in place.
+func removeId(ids []int, id int) []int {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,135 @@
+package taskstore
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// checkIndexes fails the test if the indexes of ts do not match a scan of its tasks.
+func checkIndexes(t *testing.T, ts *TaskStore) {
+	t.Helper()
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	wantTags := make(map[string][]int)
+	wantDue := make(map[dayKey][]int)
+	archived := 0
+
+	for _, task := range ts.backend.All() {
+		id := task.Id
+		for i, tag := range task.Tags {
+			if !hasTag(task.Tags[:i], tag) {
+				wantTags[tag] = append(wantTags[tag], id)
+			}
+		}
+		if !task.Due.IsZero() {
+			wantDue[dueDay(task.Due)] = append(wantDue[dueDay(task.Due)], id)
+		}
+		if task.Archived {
+			archived++
+		}
+	}
+
+	if len(ts.tagIndex) != len(wantTags) {
+		t.Errorf("tag index has %d tags, want %d", len(ts.tagIndex), len(wantTags))
+	}
+	for tag, want := range wantTags {
+		if got := sortedCopy(ts.tagIndex[tag]); !equalInts(got, sortedCopy(want)) {
+			t.Errorf("tag index for %q is %v, want %v", tag, got, sortedCopy(want))
+		}
+	}
+
+	if len(ts.dueIndex) != len(wantDue) {
+		t.Errorf("due index has %d days, want %d", len(ts.dueIndex), len(wantDue))
+	}
+	for day, want := range wantDue {
+		if got := sortedCopy(ts.dueIndex[day]); !equalInts(got, sortedCopy(want)) {
+			t.Errorf("due index for %v is %v, want %v", day, got, sortedCopy(want))
+		}
+	}
+
+	if ts.archived != archived {
+		t.Errorf("archived count is %d, want %d", ts.archived, archived)
+	}
+}
+
+func sortedCopy(ids []int) []int {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndexesMatchScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tags := []string{"a", "b", "c", "d"}
+	base := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	randomTags := func() []string {
+		var picked []string
+		for n := rnd.Intn(4); n > 0; n-- {
+			picked = append(picked, tags[rnd.Intn(len(tags))])
+		}
+		return picked
+	}
+	randomDue := func() time.Time {
+		if rnd.Intn(3) == 0 {
+			return time.Time{}
+		}
+		return base.AddDate(0, 0, rnd.Intn(5))
+	}
+
+	ts := New()
+	var ids []int
+
+	for step := 0; step < 2000; step++ {
+		var id int
+		if len(ids) > 0 {
+			id = ids[rnd.Intn(len(ids))]
+		}
+
+		switch op := rnd.Intn(10); {
+		case op < 3 || len(ids) == 0:
+			ids = append(ids, mustCreate(t, ts, "task", randomTags(), randomDue()))
+		case op == 3:
+			ts.UpdateTask(id, "task", randomTags(), randomDue())
+		case op == 4:
+			ts.AddTagToTask(id, tags[rnd.Intn(len(tags))])
+		case op == 5:
+			ts.RemoveTagFromTask(id, tags[rnd.Intn(len(tags))])
+		case op == 6:
+			ts.RenameTag(tags[rnd.Intn(len(tags))], tags[rnd.Intn(len(tags))])
+		case op == 7:
+			ts.setArchived(id, rnd.Intn(2) == 0)
+		case op == 8:
+			ts.RescheduleTask(id, randomDue())
+		default:
+			ts.DeleteTask(id)
+			for i, v := range ids {
+				if v == id {
+					ids = append(ids[:i], ids[i+1:]...)
+					break
+				}
+			}
+		}
+
+		checkIndexes(t, ts)
+		if t.Failed() {
+			t.Fatalf("indexes out of sync after step %d", step)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package taskstore
+
+// MergeReport tells what Merge did.
+type MergeReport struct {
+	// Added holds the ids given in the store to the tasks of the other store, in the order
+	// of their ids in the other store.
+	Added []int `json:"added"`
+	// Duplicates holds the added tasks that are likely duplicates of a task already in the
+	// store.
+	Duplicates []MergeDuplicate `json:"duplicates"`
+}
+
+// MergeDuplicate pairs a task added by Merge with a task that was already in the store and
+// has the same content, as told by Task.EqualContent.
+type MergeDuplicate struct {
+	ExistingId int `json:"existingId"`
+	AddedId    int `json:"addedId"`
+}
+
+// Merge copies all the tasks of other into the store and reports their ids along with the
+// likely duplicates among them, which are added all the same, so the caller can decide
+// what to do about them. The copies are given fresh ids in the store, in the order of their
+// ids in other, so ids from other must not be used to look them up; their dependencies are
+// moved to the new ids and their other fields but the version are kept. other is read from
+// a snapshot taken before locking the store, the two stores are never locked at the same
+// time, so merging stores into each other concurrently cannot deadlock. If the store runs
+// out of ids, an error is returned and nothing is added.
+func (ts *TaskStore) Merge(other *TaskStore) (MergeReport, error) {
+	other.mu.RLock()
+	tasks := other.sortedTasks()
+	for i, task := range tasks {
+		tasks[i] = copyTask(task)
+	}
+	other.mu.RUnlock()
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if err := ts.checkCapacity(len(tasks)); err != nil {
+		return MergeReport{}, err
+	}
+
+	// Only tasks with the same text can have the same content, group the existing ones by
+	// text so every added task is compared with a few of them at most.
+	byText := make(map[string][]Task)
+	for _, task := range ts.sortedTasks() {
+		byText[task.Text] = append(byText[task.Text], task)
+	}
+
+	report := MergeReport{Added: make([]int, 0, len(tasks)), Duplicates: []MergeDuplicate{}}
+
+	// The dependencies refer to ids in other, move them to the ids the tasks get here.
+	mapping := make(map[int]int, len(tasks))
+	for i, task := range tasks {
+		mapping[task.Id] = ts.nextId + i
+	}
+
+	for _, task := range tasks {
+		task.Id = ts.nextId
+		task.Version = 0
+		task.DependsOn = remapDeps(task.DependsOn, mapping)
+
+		// Keep the UUID of the task unless it is already taken, as when a store is merged
+		// into itself.
+		if _, taken := ts.uids[task.Uid]; taken {
+			task.Uid = ""
+		}
+		if task.Uid == "" && ts.useUUID {
+			task.Uid = newUUID()
+		}
+
+		for _, existing := range byText[task.Text] {
+			if existing.EqualContent(task) {
+				report.Duplicates = append(report.Duplicates, MergeDuplicate{ExistingId: existing.Id, AddedId: task.Id})
+				break
+			}
+		}
+
+		ts.putTask(task)
+		ts.nextId++
+
+		report.Added = append(report.Added, task.Id)
+	}
+
+	return report, nil
+}
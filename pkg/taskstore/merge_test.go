@@ -0,0 +1,46 @@
+package taskstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMergeReportsDuplicates(t *testing.T) {
+	due := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	ts := New()
+	existing := mustCreate(t, ts, "buy milk", []string{"home", "shop"}, due)
+	mustCreate(t, ts, "buy eggs", nil, time.Time{})
+
+	other := New()
+	mustCreate(t, other, "unused", nil, time.Time{})
+	dup := mustCreate(t, other, "buy milk", []string{"shop", "home"}, due)
+	fresh := mustCreate(t, other, "buy milk", []string{"shop"}, due)
+	if err := other.SetDependencies(fresh, []int{dup}); err != nil {
+		t.Fatal(err)
+	}
+	other.DeleteTask(0)
+
+	report, err := ts.Merge(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(report.Added) != "[2 3]" {
+		t.Errorf("Merge added %v, want [2 3]", report.Added)
+	}
+	want := []MergeDuplicate{{ExistingId: existing, AddedId: 2}}
+	if fmt.Sprint(report.Duplicates) != fmt.Sprint(want) {
+		t.Errorf("Merge reported duplicates %+v, want %+v", report.Duplicates, want)
+	}
+
+	checkIds(t, ts.GetAllTasks(), 0, 1, 2, 3)
+	if task, _ := ts.GetTask(3); fmt.Sprint(task.DependsOn) != "[2]" || fmt.Sprint(task.Tags) != "[shop]" {
+		t.Errorf("merged task is %+v, want tags [shop] depending on 2", task)
+	}
+	checkIndexes(t, ts)
+
+	// The other store is left as it was.
+	checkIds(t, other.GetAllTasks(), dup, fresh)
+}
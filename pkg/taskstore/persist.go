@@ -0,0 +1,200 @@
+package taskstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// storeData is the on-disk representation of a TaskStore.
+type storeData struct {
+	Tasks  []Task `json:"tasks"`
+	NextId int    `json:"nextId"`
+	// UseUUID tells whether the store gives every created task a UUID, see NewWithUUID.
+	UseUUID bool `json:"useUUID,omitempty"`
+}
+
+// SaveToFile writes all the tasks in the store, together with the next id and whether it
+// gives tasks UUIDs, to the file at path as JSON. The tasks are first written to a temporary file in the same directory,
+// which then replaces the file at path, so a crash while saving leaves the previous file
+// intact.
+func (ts *TaskStore) SaveToFile(path string) error {
+	b, err := json.Marshal(ts)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Removing fails harmlessly once the file was renamed.
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFromFile creates a new store holding the tasks saved in the file at path. The next id
+// is always set past the largest loaded id, even if the file says otherwise.
+func LoadFromFile(path string) (*TaskStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ts, err := ReadFrom(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load %s: %w", path, err)
+	}
+
+	return ts, nil
+}
+
+// WriteTo implements io.WriterTo, it writes the store to w in the same JSON format as
+// SaveToFile and returns the number of bytes written.
+func (ts *TaskStore) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.Marshal(ts)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// EncodeTasks writes all the tasks, sorted by id, to w as a JSON array, in the same format
+// as GetAllTasks would be marshaled. The tasks are encoded one by one while holding the
+// read lock, so large stores are streamed without first being marshaled as a whole.
+func (ts *TaskStore) EncodeTasks(w io.Writer) error {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0, ts.count-ts.archived)
+	for _, task := range ts.backend.All() {
+		if !task.Archived {
+			tasks = append(tasks, task)
+		}
+	}
+	sortById(tasks)
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+
+	for i, task := range tasks {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		if err := enc.Encode(task); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// ReadFrom creates a new store holding the tasks read from r, in the same JSON format as
+// LoadFromFile.
+func ReadFrom(r io.Reader) (*TaskStore, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var data storeData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+
+	ts := NewWithCapacity(len(data.Tasks))
+	if err := ts.load(data); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// MarshalJSON implements json.Marshaler, the tasks are emitted sorted by id along with the
+// next id and whether the store gives tasks UUIDs.
+func (ts *TaskStore) MarshalJSON() ([]byte, error) {
+	ts.mu.RLock()
+	data := storeData{Tasks: ts.sortedTasks(), NextId: ts.nextId, UseUUID: ts.useUUID}
+	ts.mu.RUnlock()
+
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it replaces the contents of the store with the
+// tasks in b. The next id is always set past the largest id in b. If b comes from a store
+// giving tasks UUIDs, this store starts giving them too, but a store already giving them
+// never stops.
+func (ts *TaskStore) UnmarshalJSON(b []byte) error {
+	var data storeData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+
+	return ts.load(data)
+}
+
+// load replaces the contents of the store with data, in a map sized for its tasks up front.
+func (ts *TaskStore) load(data storeData) error {
+	tasks := make(map[int]Task, len(data.Tasks))
+	nextId := data.NextId
+
+	for _, task := range data.Tasks {
+		if _, ok := tasks[task.Id]; ok {
+			return fmt.Errorf("duplicate task id=%d", task.Id)
+		}
+
+		tasks[task.Id] = copyTask(task)
+
+		if task.Id >= nextId {
+			nextId = task.Id + 1
+		}
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	ts.setTasks(tasks)
+	ts.dropKeys()
+	ts.nextId = nextId
+	if data.UseUUID {
+		ts.useUUID = true
+	}
+
+	ts.record(EventReset, -1, nil, nil)
+	ts.emit(EventReset, -1)
+
+	return nil
+}
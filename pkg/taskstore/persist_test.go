@@ -0,0 +1,250 @@
+package taskstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.json")
+
+	ts := New()
+	// A frozen clock avoids monotonic readings, which do not survive JSON.
+	ts.SetClock(func() time.Time { return date(2023, time.May, 1) })
+	mustCreate(t, ts, "a", []string{"x"}, date(2023, time.June, 1))
+	b := mustCreate(t, ts, "b", nil, time.Time{})
+
+	if err := os.WriteFile(path, []byte("previous content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Snapshot(), ts.Snapshot()) {
+		t.Errorf("loaded %+v, want %+v", loaded.Snapshot(), ts.Snapshot())
+	}
+	if id := mustCreate(t, loaded, "c", nil, time.Time{}); id != b+1 {
+		t.Errorf("loaded store created the id %d, want %d", id, b+1)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files after saving, want only the saved file", len(entries))
+	}
+}
+
+func TestSaveToFileKeepsTargetOnError(t *testing.T) {
+	dir := t.TempDir()
+	ts := New()
+	mustCreate(t, ts, "task", nil, time.Time{})
+
+	// A good save next to the target, which the failed save must not touch either.
+	good := filepath.Join(dir, "good.json")
+	if err := ts.SaveToFile(good); err != nil {
+		t.Fatal(err)
+	}
+	goodBytes, err := os.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The temporary file is written fine, but cannot be renamed over a non-empty directory.
+	target := filepath.Join(dir, "tasks.json")
+	kept := filepath.Join(target, "kept")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kept, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.SaveToFile(target); err == nil {
+		t.Fatal("saving over a non-empty directory did not fail")
+	}
+
+	if b, err := os.ReadFile(kept); err != nil || string(b) != "original" {
+		t.Errorf("the target now holds %q (%v), want it unchanged", b, err)
+	}
+	if b, err := os.ReadFile(good); err != nil || string(b) != string(goodBytes) {
+		t.Errorf("the file next to the target changed to %q (%v)", b, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory holds %v after the failed save, want only good.json and tasks.json", names)
+	}
+
+	if err := ts.SaveToFile(filepath.Join(dir, "missing", "tasks.json")); err == nil {
+		t.Error("saving into a missing directory did not fail")
+	}
+}
+
+func TestLoadFixesNextId(t *testing.T) {
+	ts, err := ReadFrom(strings.NewReader(`{"tasks":[{"id":7,"text":"a"}],"nextId":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := mustCreate(t, ts, "b", nil, time.Time{}); id != 8 {
+		t.Errorf("created the id %d, want 8", id)
+	}
+
+	if _, err := ReadFrom(strings.NewReader(`{"tasks":[{"id":1,"text":"a"},{"id":1,"text":"b"}]}`)); err == nil {
+		t.Error("loading duplicate ids did not fail")
+	}
+}
+
+// BenchmarkLoadFromFile compares loading a saved store into a store created with room for
+// its tasks, as LoadFromFile does, with loading it into an empty store.
+func BenchmarkLoadFromFile(b *testing.B) {
+	const n = 10000
+
+	ts := New()
+	for i := 0; i < n; i++ {
+		mustCreate(b, ts, fmt.Sprintf("task %d", i), []string{"tag"}, time.Time{})
+	}
+
+	path := filepath.Join(b.TempDir(), "tasks.json")
+	if err := ts.SaveToFile(path); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, hint := range []int{n, 0} {
+		name := "hint"
+		if hint == 0 {
+			name = "nohint"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := NewWithCapacity(hint).UnmarshalJSON(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeTasks(t *testing.T) {
+	ts := New()
+
+	var buf strings.Builder
+	if err := ts.EncodeTasks(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]" {
+		t.Errorf("empty store encoded as %q, want []", buf.String())
+	}
+
+	due := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	mustCreate(t, ts, "first", []string{"a"}, due)
+	mustCreate(t, ts, "second", nil, time.Time{})
+	archived := mustCreate(t, ts, "archived", nil, time.Time{})
+	ts.ArchiveTask(archived)
+
+	buf.Reset()
+	if err := ts.EncodeTasks(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(ts.GetAllTasks())
+
+	var got, wantTasks []Task
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("EncodeTasks wrote invalid JSON %q: %v", buf.String(), err)
+	}
+	json.Unmarshal(want, &wantTasks)
+	if !reflect.DeepEqual(got, wantTasks) {
+		t.Errorf("EncodeTasks wrote %s, want %s", buf.String(), want)
+	}
+}
+
+// BenchmarkEncodeTasks compares streaming the tasks with marshaling them all at once, the
+// bytes allocated per operation bound the memory needed on top of the store itself.
+func BenchmarkEncodeTasks(b *testing.B) {
+	ts := New()
+	for i := 0; i < 10000; i++ {
+		mustCreate(b, ts, fmt.Sprintf("task %d", i), []string{"tag"}, time.Time{})
+	}
+
+	b.Run("EncodeTasks", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := ts.EncodeTasks(io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(ts.GetAllTasks())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := io.Discard.Write(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestSaveAndLoadKeepsUUIDs(t *testing.T) {
+	ts := NewWithUUID()
+	old := mustCreate(t, ts, "old", nil, time.Time{})
+	oldTask, _ := ts.GetTask(old)
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := ts.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if task, err := loaded.GetTaskByUid(oldTask.Uid); err != nil || task.Id != old {
+		t.Errorf("GetTaskByUid after loading returned (%+v, %v), want task %d", task, err, old)
+	}
+
+	created := mustCreate(t, loaded, "new", nil, time.Time{})
+	if task, _ := loaded.GetTask(created); task.Uid == "" || task.Uid == oldTask.Uid {
+		t.Errorf("task created after loading has the uid %q, want a new one", task.Uid)
+	}
+
+	// A store without UUIDs stays without them.
+	plain, err := ReadFrom(strings.NewReader(`{"tasks":[{"id":0,"text":"a"}],"nextId":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := plain.GetTask(mustCreate(t, plain, "b", nil, time.Time{})); task.Uid != "" {
+		t.Errorf("task created in a store without UUIDs got the uid %q", task.Uid)
+	}
+}
@@ -0,0 +1,43 @@
+package taskstore
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// SetRandSource makes GetRandomTask draw from src, which lets tests seed it
+// deterministically.
+func (ts *TaskStore) SetRandSource(src rand.Source) {
+	ts.randMu.Lock()
+	defer ts.randMu.Unlock()
+
+	ts.rand = rand.New(src)
+}
+
+// GetRandomTask returns a task that is not archived picked uniformly at random. If there is
+// no such task, an error is returned.
+func (ts *TaskStore) GetRandomTask() (Task, error) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	// Sorting makes the pick only depend on the random source.
+	tasks := ts.activeTasks()
+	if len(tasks) == 0 {
+		return Task{}, fmt.Errorf("no task to pick from")
+	}
+
+	return tasks[ts.intn(len(tasks))], nil
+}
+
+// intn returns a random number in [0, n) from the random source of the store.
+func (ts *TaskStore) intn(n int) int {
+	ts.randMu.Lock()
+	defer ts.randMu.Unlock()
+
+	if ts.rand == nil {
+		ts.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return ts.rand.Intn(n)
+}
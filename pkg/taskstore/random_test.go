@@ -0,0 +1,52 @@
+package taskstore
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestGetRandomTaskPicksEveryTask(t *testing.T) {
+	ts := New()
+	if _, err := ts.GetRandomTask(); err == nil {
+		t.Error("GetRandomTask on an empty store succeeded")
+	}
+
+	const n = 5
+	for i := 0; i < n; i++ {
+		mustCreate(t, ts, "task", nil, time.Time{})
+	}
+	archived := mustCreate(t, ts, "archived", nil, time.Time{})
+	ts.ArchiveTask(archived)
+
+	ts.SetRandSource(rand.NewSource(1))
+	picked := make(map[int]int)
+	var first []int
+	for i := 0; i < 500; i++ {
+		task, err := ts.GetRandomTask()
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked[task.Id]++
+		if i < 10 {
+			first = append(first, task.Id)
+		}
+	}
+
+	if picked[archived] != 0 {
+		t.Errorf("the archived task was picked %d times", picked[archived])
+	}
+	for id := 0; id < n; id++ {
+		if picked[id] == 0 {
+			t.Errorf("task %d was never picked in 500 draws", id)
+		}
+	}
+
+	// The same seed gives the same picks.
+	ts.SetRandSource(rand.NewSource(1))
+	for i, want := range first {
+		if task, _ := ts.GetRandomTask(); task.Id != want {
+			t.Fatalf("draw %d after reseeding picked %d, want %d", i, task.Id, want)
+		}
+	}
+}
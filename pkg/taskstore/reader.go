@@ -0,0 +1,21 @@
+package taskstore
+
+import "time"
+
+// TaskReader is the read-only part of the TaskStore API. Code that only needs to look at
+// tasks can accept a TaskReader, so it cannot change the store it is given.
+type TaskReader interface {
+	GetTask(id int) (Task, error)
+	Exists(id int) bool
+	GetAllTasks() []Task
+	GetTasksByTag(tag string) []Task
+	GetTasksByTags(tags []string, matchAll bool) []Task
+	GetTasksByDueDate(year int, month time.Month, day int) []Task
+	GetTasksByDueRange(start, end time.Time) []Task
+	SearchTasks(query string) []Task
+	GetAllTags() []string
+	Count() int
+	CountByTag(tag string) int
+}
+
+var _ TaskReader = (*TaskStore)(nil)
@@ -0,0 +1,106 @@
+package taskstore
+
+import (
+	"fmt"
+	"time"
+)
+
+// Recurrence describes how often a task repeats.
+type Recurrence string
+
+const (
+	RecurNone    Recurrence = ""
+	RecurDaily   Recurrence = "daily"
+	RecurWeekly  Recurrence = "weekly"
+	RecurMonthly Recurrence = "monthly"
+)
+
+// next returns the first occurrence after t. Monthly recurrences are clamped to the last
+// day of the month, so the occurrence after January 31 is the last day of February.
+func (r Recurrence) next(t time.Time) (time.Time, error) {
+	return r.nth(t, 1)
+}
+
+// nth returns the n-th occurrence after t. Monthly occurrences are counted in months from t
+// and clamped separately, so the third occurrence after January 31 is April 30 even though
+// the first one is clamped to the end of February.
+func (r Recurrence) nth(t time.Time, n int) (time.Time, error) {
+	switch r {
+	case RecurDaily:
+		return t.AddDate(0, 0, n), nil
+	case RecurWeekly:
+		return t.AddDate(0, 0, 7*n), nil
+	case RecurMonthly:
+		y, m, d := t.Date()
+		hour, minute, sec := t.Clock()
+		month := m + time.Month(n)
+		// Day 0 of the month after the target is the last day of the target month.
+		last := time.Date(y, month+1, 0, 0, 0, 0, 0, t.Location()).Day()
+		if d > last {
+			d = last
+		}
+		return time.Date(y, month, d, hour, minute, sec, t.Nanosecond(), t.Location()), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unknown recurrence %q", r)
+}
+
+// CompleteRecurring marks the recurring task with the given id as done and creates its next
+// occurrence, which is returned. The next occurrence is due one interval after the current
+// one, skipping the occurrences that are already in the past at now, or one interval after
+// now if the task has no due date. If no such id exists, the task is not recurring or it is
+// already done, which means its next occurrence was already created, an error is returned.
+func (ts *TaskStore) CompleteRecurring(id int, now time.Time) (Task, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return Task{}, taskNotFound(id)
+	}
+
+	if task.Recurrence == RecurNone {
+		return Task{}, fmt.Errorf("task with id=%d is not recurring", id)
+	}
+
+	if task.Done {
+		return Task{}, fmt.Errorf("recurring task with id=%d is already done", id)
+	}
+
+	if err := ts.checkCapacity(1); err != nil {
+		return Task{}, err
+	}
+
+	due := task.Due
+	if due.IsZero() {
+		due = now
+	}
+
+	// Every occurrence is counted from the current due date, so that clamping one monthly
+	// occurrence to a short month does not move the later ones.
+	start := due
+	due, err := task.Recurrence.next(start)
+	if err != nil {
+		return Task{}, err
+	}
+
+	for n := 2; !due.After(now); n++ {
+		if due, err = task.Recurrence.nth(start, n); err != nil {
+			return Task{}, err
+		}
+	}
+
+	task.Done = true
+	ts.putTask(task)
+
+	next := ts.insertTask(TaskInput{
+		Text:       task.Text,
+		Notes:      task.Notes,
+		Tags:       task.Tags,
+		Due:        due,
+		Priority:   task.Priority,
+		Recurrence: task.Recurrence,
+	})
+
+	return copyTask(next), nil
+}
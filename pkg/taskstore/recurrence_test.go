@@ -0,0 +1,94 @@
+package taskstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	tests := []struct {
+		recurrence Recurrence
+		from, want time.Time
+	}{
+		{RecurDaily, date(2023, time.January, 31), date(2023, time.February, 1)},
+		{RecurWeekly, date(2023, time.December, 28), date(2024, time.January, 4)},
+		{RecurMonthly, date(2023, time.January, 31), date(2023, time.February, 28)},
+		{RecurMonthly, date(2024, time.January, 31), date(2024, time.February, 29)},
+		{RecurMonthly, date(2023, time.December, 31), date(2024, time.January, 31)},
+		{RecurMonthly, date(2023, time.March, 15), date(2023, time.April, 15)},
+	}
+
+	for _, test := range tests {
+		got, err := test.recurrence.next(test.from)
+		if err != nil || !got.Equal(test.want) {
+			t.Errorf("%s next(%v) = %v, %v, want %v", test.recurrence, test.from, got, err, test.want)
+		}
+	}
+}
+
+func TestCompleteRecurring(t *testing.T) {
+	ts := New()
+	now := date(2023, time.January, 20)
+
+	id, err := ts.CreateTaskFromInput(TaskInput{Text: "rent", Due: date(2023, time.January, 31), Recurrence: RecurMonthly})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := ts.CompleteRecurring(id, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := date(2023, time.February, 28); !next.Due.Equal(want) || next.Done || next.Recurrence != RecurMonthly {
+		t.Errorf("next occurrence is %+v, want an open monthly task due %v", next, want)
+	}
+	if task, _ := ts.GetTask(id); !task.Done {
+		t.Error("the completed occurrence is not done")
+	}
+
+	if _, err := ts.CompleteRecurring(id, now); err == nil {
+		t.Error("completing an occurrence twice did not fail")
+	}
+	if n := ts.Count(); n != 2 {
+		t.Errorf("store has %d tasks, want 2", n)
+	}
+
+	plain := mustCreate(t, ts, "plain", nil, time.Time{})
+	if _, err := ts.CompleteRecurring(plain, now); err == nil {
+		t.Error("completing a task that is not recurring did not fail")
+	}
+}
+
+func TestCompleteRecurringCatchesUp(t *testing.T) {
+	tests := []struct {
+		recurrence Recurrence
+		due, now   time.Time
+		want       time.Time
+	}{
+		// The February occurrence is clamped, the April one must not be.
+		{RecurMonthly, date(2023, time.January, 31), date(2023, time.April, 5), date(2023, time.April, 30)},
+		{RecurMonthly, date(2024, time.January, 30), date(2024, time.March, 1), date(2024, time.March, 30)},
+		{RecurMonthly, date(2023, time.January, 31), date(2023, time.March, 31), date(2023, time.April, 30)},
+		{RecurWeekly, date(2023, time.February, 27), date(2023, time.March, 14), date(2023, time.March, 20)},
+		{RecurDaily, date(2023, time.February, 27), date(2023, time.March, 2), date(2023, time.March, 3)},
+	}
+
+	for _, test := range tests {
+		ts := New()
+		id, err := ts.CreateTaskFromInput(TaskInput{Text: "rent", Due: test.due, Recurrence: test.recurrence})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		next, err := ts.CompleteRecurring(id, test.now)
+		if err != nil || !next.Due.Equal(test.want) {
+			t.Errorf("%s task due %v completed at %v is next due %v, %v, want %v",
+				test.recurrence, test.due, test.now, next.Due, err, test.want)
+		}
+	}
+}
+
+// date returns midnight UTC on the given day.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
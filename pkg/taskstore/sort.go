@@ -0,0 +1,95 @@
+package taskstore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortField names a field of Task that GetTasksSorted can sort by.
+type SortField string
+
+const (
+	SortById       SortField = "id"
+	SortByText     SortField = "text"
+	SortByDue      SortField = "due"
+	SortByPriority SortField = "priority"
+)
+
+// SortKey is one step of the order passed to GetTasksSorted.
+type SortKey struct {
+	Field      SortField
+	Descending bool
+}
+
+// GetTasksSorted returns all the tasks sorted by the given keys, the first key deciding the
+// order and each following key breaking the ties left by the previous ones. Tasks still
+// tied after the last key are sorted by id. An error is returned, and nothing is sorted, if
+// a key names an unknown field. As with GetTasksSortedByDue, tasks without a due date come
+// last when sorting by due date, whatever the direction.
+func (ts *TaskStore) GetTasksSorted(by []SortKey) ([]Task, error) {
+	for _, key := range by {
+		switch key.Field {
+		case SortById, SortByText, SortByDue, SortByPriority:
+		default:
+			return nil, fmt.Errorf("cannot sort by unknown field %q", key.Field)
+		}
+	}
+
+	ts.mu.RLock()
+	tasks := ts.activeTasks()
+	ts.mu.RUnlock()
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		for _, key := range by {
+			if c := compareBy(key.Field, tasks[i], tasks[j]); c != 0 {
+				if key.Descending && (key.Field != SortByDue || !tasks[i].Due.IsZero() && !tasks[j].Due.IsZero()) {
+					c = -c
+				}
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	return tasks, nil
+}
+
+// compareBy returns -1, 0 or +1 depending on whether a comes before, with, or after b in
+// the ascending order of field. Tasks without a due date come after the others.
+func compareBy(field SortField, a, b Task) int {
+	switch field {
+	case SortById:
+		return compareInts(a.Id, b.Id)
+	case SortByText:
+		return strings.Compare(a.Text, b.Text)
+	case SortByPriority:
+		return compareInts(a.Priority, b.Priority)
+	case SortByDue:
+		switch {
+		case a.Due.Equal(b.Due):
+			return 0
+		case a.Due.IsZero():
+			return 1
+		case b.Due.IsZero():
+			return -1
+		case a.Due.Before(b.Due):
+			return -1
+		default:
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareInts returns -1, 0 or +1 depending on whether a is less than, equal to, or greater
+// than b.
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
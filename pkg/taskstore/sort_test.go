@@ -0,0 +1,60 @@
+package taskstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetTasksSorted(t *testing.T) {
+	early := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	late := early.AddDate(0, 0, 1)
+
+	ts := New()
+	for _, input := range []TaskInput{
+		{Text: "b", Priority: 2, Due: late},
+		{Text: "a", Priority: 1},
+		{Text: "c", Priority: 2, Due: early},
+		{Text: "a", Priority: 3, Due: late},
+	} {
+		if _, err := ts.CreateTaskFromInput(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archived := mustCreate(t, ts, "archived", nil, time.Time{})
+	ts.ArchiveTask(archived)
+
+	tests := []struct {
+		name string
+		by   []SortKey
+		want []int
+	}{
+		{"no keys", nil, []int{0, 1, 2, 3}},
+		{"id", []SortKey{{Field: SortById}}, []int{0, 1, 2, 3}},
+		{"id descending", []SortKey{{Field: SortById, Descending: true}}, []int{3, 2, 1, 0}},
+		{"text", []SortKey{{Field: SortByText}}, []int{1, 3, 0, 2}},
+		{"text descending", []SortKey{{Field: SortByText, Descending: true}}, []int{2, 0, 1, 3}},
+		{"priority", []SortKey{{Field: SortByPriority}}, []int{1, 0, 2, 3}},
+		{"priority descending", []SortKey{{Field: SortByPriority, Descending: true}}, []int{3, 0, 2, 1}},
+		// The task without a due date comes last in both directions.
+		{"due", []SortKey{{Field: SortByDue}}, []int{2, 0, 3, 1}},
+		{"due descending", []SortKey{{Field: SortByDue, Descending: true}}, []int{0, 3, 2, 1}},
+		{"text then priority descending", []SortKey{{Field: SortByText}, {Field: SortByPriority, Descending: true}}, []int{3, 1, 0, 2}},
+		{"priority descending then text", []SortKey{{Field: SortByPriority, Descending: true}, {Field: SortByText}}, []int{3, 0, 2, 1}},
+		{"due then text descending", []SortKey{{Field: SortByDue}, {Field: SortByText, Descending: true}}, []int{2, 0, 3, 1}},
+	}
+
+	for _, test := range tests {
+		tasks, err := ts.GetTasksSorted(test.by)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := taskIds(tasks); !equalInts(got, test.want) {
+			t.Errorf("%s: got tasks %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if _, err := ts.GetTasksSorted([]SortKey{{Field: SortByText}, {Field: "color"}}); err == nil {
+		t.Error("sorting by an unknown field succeeded")
+	}
+}
@@ -0,0 +1,134 @@
+package taskstore
+
+import "time"
+
+// StoreStats holds aggregate numbers about the tasks in a store.
+type StoreStats struct {
+	// TotalTasks is the number of tasks in the store.
+	TotalTasks int `json:"totalTasks"`
+	// DistinctTags is the number of different tags used by the tasks.
+	DistinctTags int `json:"distinctTags"`
+	// OverdueCount is the number of tasks with a due date before the time given to Stats.
+	OverdueCount int `json:"overdueCount"`
+	// TasksPerTag maps every tag to the number of tasks that have it.
+	TasksPerTag map[string]int `json:"tasksPerTag"`
+}
+
+// Stats returns aggregate numbers about the tasks in the store that are not archived,
+// overdue tasks are counted relative to now. All the numbers are computed from the same
+// consistent state.
+func (ts *TaskStore) Stats(now time.Time) StoreStats {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	stats := StoreStats{
+		TotalTasks:  ts.count - ts.archived,
+		TasksPerTag: ts.tagCounts(),
+	}
+
+	stats.DistinctTags = len(stats.TasksPerTag)
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && !task.Due.IsZero() && task.Due.Before(now) {
+			stats.OverdueCount++
+		}
+	}
+
+	return stats
+}
+
+// TagCounts returns, for every tag, the number of tasks that are not archived and have it.
+// A task is counted once per distinct tag, so the counts can add up to more than Count.
+func (ts *TaskStore) TagCounts() map[string]int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return ts.tagCounts()
+}
+
+// tagCounts is TagCounts, the caller must hold the lock.
+func (ts *TaskStore) tagCounts() map[string]int {
+	counts := make(map[string]int, len(ts.tagIndex))
+
+	for tag, ids := range ts.tagIndex {
+		for _, id := range ids {
+			if !ts.task(id).Archived {
+				counts[tag]++
+			}
+		}
+	}
+
+	return counts
+}
+
+// DueHistogram returns, for every day with tasks due between start and end, both bounds
+// inclusive, the number of such tasks that are not archived. Days are keyed by their
+// midnight in UTC, whatever the location of the due dates, so a task due at 23:30 in
+// UTC-1 is counted on the next day. Days without tasks are left out of the map.
+func (ts *TaskStore) DueHistogram(start, end time.Time) map[time.Time]int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	histogram := make(map[time.Time]int)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived || task.Due.IsZero() || task.Due.Before(start) || task.Due.After(end) {
+			continue
+		}
+
+		y, m, d := task.Due.UTC().Date()
+		histogram[time.Date(y, m, d, 0, 0, 0, 0, time.UTC)]++
+	}
+
+	return histogram
+}
+
+// The due statuses counted by DueStatusCounts.
+const (
+	DueStatusOverdue  = "overdue"
+	DueStatusDueToday = "due-today"
+	DueStatusUpcoming = "upcoming"
+	DueStatusNoDue    = "no-due"
+	DueStatusDone     = "done"
+)
+
+// DueStatusCounts returns the number of tasks that are not archived in each due status,
+// every status present even if no task has it. Every task has exactly one status:
+//   - done: the task is done, whatever its due date,
+//   - no-due: the task is not done and has no due date,
+//   - overdue: the task is not done and is due before now,
+//   - due-today: the task is not done and is due later on the date of now, the date of the
+//     task being read in the location of its own due date as with GetTasksByDueDate,
+//   - upcoming: every other task, which is not done and is due after now on another date.
+func (ts *TaskStore) DueStatusCounts(now time.Time) map[string]int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	counts := map[string]int{
+		DueStatusOverdue:  0,
+		DueStatusDueToday: 0,
+		DueStatusUpcoming: 0,
+		DueStatusNoDue:    0,
+		DueStatusDone:     0,
+	}
+
+	today := dueDay(now)
+
+	for _, task := range ts.backend.All() {
+		switch {
+		case task.Archived:
+		case task.Done:
+			counts[DueStatusDone]++
+		case task.Due.IsZero():
+			counts[DueStatusNoDue]++
+		case task.Due.Before(now):
+			counts[DueStatusOverdue]++
+		case dueDay(task.Due) == today:
+			counts[DueStatusDueToday]++
+		default:
+			counts[DueStatusUpcoming]++
+		}
+	}
+
+	return counts
+}
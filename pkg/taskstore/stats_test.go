@@ -0,0 +1,109 @@
+package taskstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	ts := New()
+	mustCreate(t, ts, "overdue", []string{"a", "b"}, now.Add(-time.Hour))
+	mustCreate(t, ts, "upcoming", []string{"a"}, now.Add(time.Hour))
+	mustCreate(t, ts, "no due", nil, time.Time{})
+	archived := mustCreate(t, ts, "archived", []string{"a", "c"}, now.Add(-time.Hour))
+	ts.ArchiveTask(archived)
+
+	want := StoreStats{
+		TotalTasks:   3,
+		DistinctTags: 2,
+		OverdueCount: 1,
+		TasksPerTag:  map[string]int{"a": 2, "b": 1},
+	}
+	if got := ts.Stats(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	if got := New().Stats(now); !reflect.DeepEqual(got, StoreStats{TasksPerTag: map[string]int{}}) {
+		t.Errorf("Stats() of an empty store = %+v", got)
+	}
+}
+
+func TestTagCountsExceedTaskCount(t *testing.T) {
+	ts := New()
+	mustCreate(t, ts, "both", []string{"a", "b"}, time.Time{})
+	mustCreate(t, ts, "a", []string{"a"}, time.Time{})
+	archived := mustCreate(t, ts, "archived", []string{"a", "c"}, time.Time{})
+	ts.ArchiveTask(archived)
+
+	counts := ts.TagCounts()
+	if want := map[string]int{"a": 2, "b": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("TagCounts() = %v, want %v", counts, want)
+	}
+
+	sum := 0
+	for _, n := range counts {
+		sum += n
+	}
+	if sum <= ts.Count() {
+		t.Errorf("tag counts add up to %d, want more than the %d tasks", sum, ts.Count())
+	}
+}
+
+func TestDueHistogramDayBoundaries(t *testing.T) {
+	day := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	next := day.AddDate(0, 0, 1)
+
+	ts := New()
+	mustCreate(t, ts, "midnight", nil, day)
+	mustCreate(t, ts, "last nanosecond", nil, next.Add(-time.Nanosecond))
+	mustCreate(t, ts, "next midnight", nil, next)
+	// 23:30 in UTC-1 is already the next day in UTC.
+	mustCreate(t, ts, "late elsewhere", nil, time.Date(2023, time.March, 1, 23, 30, 0, 0, time.FixedZone("UTC-1", -3600)))
+	mustCreate(t, ts, "no due", nil, time.Time{})
+
+	want := map[time.Time]int{day: 2, next: 2}
+	if got := ts.DueHistogram(day, next.Add(time.Hour)); !reflect.DeepEqual(got, want) {
+		t.Errorf("DueHistogram() = %v, want %v", got, want)
+	}
+
+	// Both bounds are inclusive.
+	want = map[time.Time]int{day: 1}
+	if got := ts.DueHistogram(day, day); !reflect.DeepEqual(got, want) {
+		t.Errorf("DueHistogram() from midnight to midnight = %v, want %v", got, want)
+	}
+}
+
+func TestDueStatusCounts(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	ts := New()
+	mustCreate(t, ts, "overdue", nil, now.Add(-time.Hour))
+	mustCreate(t, ts, "due today", nil, now.Add(time.Hour))
+	mustCreate(t, ts, "upcoming", nil, now.AddDate(0, 0, 1))
+	mustCreate(t, ts, "no due", nil, time.Time{})
+	done := mustCreate(t, ts, "done", nil, now.Add(-time.Hour))
+	ts.SetTaskDone(done, true)
+	archived := mustCreate(t, ts, "archived", nil, now.Add(-time.Hour))
+	ts.ArchiveTask(archived)
+
+	want := map[string]int{
+		DueStatusOverdue:  1,
+		DueStatusDueToday: 1,
+		DueStatusUpcoming: 1,
+		DueStatusNoDue:    1,
+		DueStatusDone:     1,
+	}
+	if got := ts.DueStatusCounts(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("DueStatusCounts() = %v, want %v", got, want)
+	}
+
+	for status := range want {
+		want[status] = 0
+	}
+	if got := New().DueStatusCounts(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("DueStatusCounts() of an empty store = %v, want %v", got, want)
+	}
+}
@@ -1,141 +1,2133 @@
 // The "taskstore" package provides a simple in-memory datastore for tasks
-// it uses mutex from the package "sync" to allow concurrent access
+// it uses a read-write mutex from the package "sync" to allow concurrent access
+// tasks are always handed out as copies, so changing them does not change the store
 
 package taskstore
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrTaskNotFound is returned, wrapped, by the methods looking up a task id that does not exist.
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrVersionConflict is returned, wrapped, by UpdateTaskIfVersion when the task was changed
+// since the expected version.
+var ErrVersionConflict = errors.New("task version conflict")
+
+// ErrStoreFull is returned, wrapped, when creating tasks would exceed the limit set with
+// SetMaxTasks.
+var ErrStoreFull = errors.New("store full")
+
+// ErrIdOverflow is returned, wrapped, when creating a task would need an id past math.MaxInt.
+var ErrIdOverflow = errors.New("task ids exhausted")
+
 type Task struct {
-	Id   int       `json:"id"`
-	Text string    `json:"text"`
-	Tags []string  `json:"tags"`
-	Due  time.Time `json:"due"`
+	Id         int        `json:"id"`
+	Text       string     `json:"text"`
+	Notes      string     `json:"notes"`
+	Tags       []string   `json:"tags"`
+	Due        time.Time  `json:"due"`
+	Done       bool       `json:"done"`
+	Priority   int        `json:"priority"`
+	Uid        string     `json:"uid,omitempty"`
+	Recurrence Recurrence `json:"recurrence,omitempty"`
+	DependsOn  []int      `json:"dependsOn,omitempty"`
+	Archived   bool       `json:"archived"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	Version    int        `json:"version"`
+}
+
+// MarshalJSON implements json.Marshaler, it leaves out the due date of tasks that have none
+// instead of emitting the zero time.
+func (t Task) MarshalJSON() ([]byte, error) {
+	// task has the fields of Task but not its methods, which avoids recursing here.
+	type task Task
+
+	aux := struct {
+		task
+		Due *time.Time `json:"due,omitempty"`
+	}{task: task(t)}
+
+	if !t.Due.IsZero() {
+		aux.Due = &t.Due
+	}
+
+	return json.Marshal(aux)
+}
+
+// EqualContent reports whether t and other have the same text, due date and set of tags,
+// regardless of the order of the tags. Every other field, the id and timestamps included,
+// is ignored.
+func (t Task) EqualContent(other Task) bool {
+	if t.Text != other.Text || !t.Due.Equal(other.Due) {
+		return false
+	}
+
+	tags := make(map[string]bool, len(t.Tags))
+	for _, tag := range t.Tags {
+		tags[tag] = true
+	}
+
+	otherTags := make(map[string]bool, len(other.Tags))
+	for _, tag := range other.Tags {
+		if !tags[tag] {
+			return false
+		}
+		otherTags[tag] = true
+	}
+
+	return len(tags) == len(otherTags)
+}
+
+// TaskInput holds the fields needed to create a task.
+type TaskInput struct {
+	Text       string
+	Notes      string
+	Tags       []string
+	Due        time.Time
+	Priority   int
+	Recurrence Recurrence
+}
+
+// TaskUpdate describes a partial update of a task, only the non-nil fields are applied.
+type TaskUpdate struct {
+	Text       *string
+	Notes      *string
+	Tags       *[]string
+	Due        *time.Time
+	Priority   *int
+	Recurrence *Recurrence
 }
 
 type TaskStore struct {
-	mu     sync.Mutex
-	tasks  map[int]Task
+	mu     sync.RWMutex
 	nextId int
+	clock  func() time.Time
+
+	// backend holds the tasks, see NewWithBackend, and count is the number of them.
+	backend Backend
+	count   int
+
+	archived int
+
+	useUUID  bool
+	uids     map[string]int
+	tagIndex map[string][]int
+	dueIndex map[dayKey][]int
+
+	maxDueYearsInPast int
+	maxTags           int
+	maxTasks          int
+
+	// keys maps the idempotency keys of CreateTaskWithKey to the ids of their tasks, and
+	// keyOf maps them back.
+	keys   map[string]idempotencyKey
+	keyOf  map[int]string
+	keyTTL time.Duration
+
+	// audit is a ring buffer of at most auditSize entries, the oldest at auditStart.
+	audit      []AuditEntry
+	auditStart int
+	auditSize  int
+
+	subMu       sync.Mutex
+	subscribers map[int]chan TaskEvent
+	nextSubId   int
+	// rand is not safe for concurrent use, randMu lets readers share it.
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	// view holds the []Task returned by SnapshotView, or nil once a change made it stale.
+	view atomic.Value
+
+	closed bool
 }
 
 func New() *TaskStore {
+	return NewWithCapacity(0)
+}
+
+// NewWithCapacity creates a new store with room for about hint tasks, which avoids growing
+// the store while creating many tasks one by one. LoadFromFile and ReadFrom need no hint, as
+// they size the store for the tasks they read.
+func NewWithCapacity(hint int) *TaskStore {
 	ts := &TaskStore{}
-	ts.tasks = make(map[int]Task)
+	ts.backend = newMemoryBackend(hint)
+	ts.clock = time.Now
+	ts.uids = make(map[string]int)
+	ts.tagIndex = make(map[string][]int)
+	ts.dueIndex = make(map[dayKey][]int)
 	ts.nextId = 0
 	return ts
 }
 
-// CreateTask creates a new task in the store.
-func (ts *TaskStore) CreateTask(text string, tags []string, due time.Time) int {
+// NewWithStartId creates a new store whose first task gets the id start.
+func NewWithStartId(start int) *TaskStore {
+	ts := New()
+	ts.nextId = start
+	return ts
+}
+
+// ResetIds makes the next created task get the id 0 again. It can only be called on an
+// empty store, otherwise an error is returned.
+func (ts *TaskStore) ResetIds() error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	task := Task{
-		Id:   ts.nextId,
-		Text: text,
-		Due:  due,
+	if ts.closed {
+		return ErrStoreClosed
 	}
 
-	task.Tags = make([]string, len(tags))
+	if ts.count > 0 {
+		return fmt.Errorf("cannot reset ids, the store still has %d tasks", ts.count)
+	}
 
-	copy(task.Tags, tags)
+	ts.nextId = 0
+	return nil
+}
 
-	ts.tasks[ts.nextId] = task
-	ts.nextId++
+// CreateTask creates a new task in the store and returns its id. If the task is not valid,
+// an error is returned.
+func (ts *TaskStore) CreateTask(text string, tags []string, due time.Time) (int, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
 
-	return task.Id
+	task, err := ts.createTask(TaskInput{Text: text, Tags: tags, Due: due})
+	return task.Id, err
 }
 
-// GetTask retrieves a task from the store, by id. If no such id exists, an error is returned.
-func (ts *TaskStore) GetTask(id int) (Task, error) {
+// CreateTaskReturning is like CreateTask but returns the whole created task.
+func (ts *TaskStore) CreateTaskReturning(text string, tags []string, due time.Time) (Task, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	if task, ok := ts.tasks[id]; ok {
-		return task, nil
+	task, err := ts.createTask(TaskInput{Text: text, Tags: tags, Due: due})
+	if err != nil {
+		return Task{}, err
 	}
 
-	return Task{}, fmt.Errorf("task with id=%d does not exist", id)
+	return copyTask(task), nil
+}
+
+// CreateTaskWithPriority creates a new task with the given priority in the store, higher
+// priorities are more urgent.
+func (ts *TaskStore) CreateTaskWithPriority(text string, tags []string, due time.Time, priority int) (int, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
 
+	task, err := ts.createTask(TaskInput{Text: text, Tags: tags, Due: due, Priority: priority})
+	return task.Id, err
 }
 
-// GetAllTasks returns all the tasks in the store, in arbitrary order.
-func (ts *TaskStore) GetAllTasks() []Task {
+// CreateTaskFromInput creates a new task with all the fields of input in the store and
+// returns its id. If the task is not valid, an error is returned.
+func (ts *TaskStore) CreateTaskFromInput(input TaskInput) (int, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	allTasks := make([]Task, 0, len(ts.tasks))
+	task, err := ts.createTask(input)
+	return task.Id, err
+}
 
-	for _, task := range ts.tasks {
-		allTasks = append(allTasks, task)
+// UpsertByText updates the tags and due date of the task whose text is exactly text, or
+// creates a new task if there is none, and returns its id and whether it was created. When
+// several tasks have the same text, the one with the lowest id is updated. If the task is
+// not valid, an error is returned.
+func (ts *TaskStore) UpsertByText(text string, tags []string, due time.Time) (id int, created bool, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0, false, ErrStoreClosed
 	}
 
-	return allTasks
+	var match Task
+	found := false
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && task.Text == text && (!found || task.Id < match.Id) {
+			match = task
+			found = true
+		}
+	}
+
+	if !found {
+		task, err := ts.createTask(TaskInput{Text: text, Tags: tags, Due: due})
+		if err != nil {
+			return 0, false, err
+		}
+		return task.Id, true, nil
+	}
 
+	if err := ts.validate(text, tags, due); err != nil {
+		return 0, false, err
+	}
+
+	match.Tags = trimTags(tags)
+	match.Due = due
+
+	ts.putTask(match)
+
+	return match.Id, false, nil
 }
 
-// GetTasksByTag returns all the tasks that have the given tag, in arbitrary order.
-func (ts *TaskStore) GetTasksByTag(tag string) []Task {
+// CreateTasks creates a new task for every input, all at once, and returns their ids in
+// the same order as inputs. If any input is not valid or the tasks do not all fit in the
+// store, an error is returned and no task is created.
+func (ts *TaskStore) CreateTasks(inputs []TaskInput) ([]int, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	var tasks []Task
+	for i, input := range inputs {
+		if err := ts.validate(input.Text, input.Tags, input.Due); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+
+	if err := ts.checkCapacity(len(inputs)); err != nil {
+		return nil, err
+	}
 
-	for _, task := range ts.tasks {
+	ids := make([]int, len(inputs))
 
-		for _, taskTag := range task.Tags {
+	for i, input := range inputs {
+		ids[i] = ts.insertTask(input).Id
+	}
 
-			if taskTag == tag {
-				tasks = append(tasks, task)
-				break
+	return ids, nil
+}
 
-			}
+// createTask validates input and adds it to the store as a new task, the caller must hold
+// the lock.
+func (ts *TaskStore) createTask(input TaskInput) (Task, error) {
+	if err := ts.validate(input.Text, input.Tags, input.Due); err != nil {
+		return Task{}, err
+	}
+
+	if err := ts.checkCapacity(1); err != nil {
+		return Task{}, err
+	}
+
+	return ts.insertTask(input), nil
+}
+
+// checkCapacity returns an error if n more tasks do not fit in the store, cannot be given
+// an id without overflowing or the store is closed, the caller must hold the lock.
+func (ts *TaskStore) checkCapacity(n int) error {
+	if ts.closed {
+		return ErrStoreClosed
+	}
 
+	if room := ts.maxTasks - ts.count; ts.maxTasks > 0 && n > room {
+		if room < 0 {
+			room = 0
 		}
+		return fmt.Errorf("cannot create %d tasks, only %d more fit in the store: %w", n, room, ErrStoreFull)
 	}
-	return tasks
+
+	if ts.nextId > math.MaxInt-n {
+		return fmt.Errorf("cannot create %d tasks from id=%d, compact the ids or restart the store: %w", n, ts.nextId, ErrIdOverflow)
+	}
+
+	return nil
 }
 
-// GetTasksByDueDate returns all the tasks that have the given due date, in arbitrary order.
-func (ts *TaskStore) GetTasksByDueDate(year int, month time.Month, day int) []Task {
+// insertTask adds a new task to the store and returns it, the caller must hold the lock.
+func (ts *TaskStore) insertTask(input TaskInput) Task {
+	task := Task{
+		Id:         ts.nextId,
+		Text:       input.Text,
+		Notes:      input.Notes,
+		Due:        input.Due,
+		Priority:   input.Priority,
+		Recurrence: input.Recurrence,
+		CreatedAt:  ts.now(),
+	}
+
+	task.Tags = trimTags(input.Tags)
+
+	if ts.useUUID {
+		task.Uid = newUUID()
+	}
+
+	task = ts.putTask(task)
+	ts.nextId++
+
+	return task
+}
+
+// CloneTask creates a new task with the same content as the task with the given id and
+// returns its id. The clone is not done, regardless of the original. If no such id exists,
+// an error is returned.
+func (ts *TaskStore) CloneTask(id int) (int, error) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	var tasks []Task
+	if ts.closed {
+		return 0, ErrStoreClosed
+	}
 
-	for _, task := range ts.tasks {
-		y, m, d := task.Due.Date()
-		if y == year && m == month && d == day {
-			tasks = append(tasks, task)
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return 0, taskNotFound(id)
+	}
+
+	if err := ts.checkCapacity(1); err != nil {
+		return 0, err
+	}
+
+	clone := ts.insertTask(TaskInput{
+		Text:       task.Text,
+		Notes:      task.Notes,
+		Tags:       task.Tags,
+		Due:        task.Due,
+		Priority:   task.Priority,
+		Recurrence: task.Recurrence,
+	})
+
+	return clone.Id, nil
+}
+
+// GetTask retrieves a task from the store, by id. If no such id exists, an error is returned.
+func (ts *TaskStore) GetTask(id int) (Task, error) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if task, ok := ts.backend.Get(id); ok {
+		return copyTask(task), nil
+	}
+
+	return Task{}, taskNotFound(id)
+
+}
+
+// GetTasks retrieves the tasks with the given ids from the store, in the order of ids, and
+// returns them along with the ids that do not exist.
+func (ts *TaskStore) GetTasks(ids []int) ([]Task, []int) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0, len(ids))
+	var missing []int
+
+	for _, id := range ids {
+		if task, ok := ts.backend.Get(id); ok {
+			tasks = append(tasks, copyTask(task))
+		} else {
+			missing = append(missing, id)
 		}
 	}
 
-	return tasks
+	return tasks, missing
 }
 
-// DeleteTask deletes the task with the given id. If no such id exists, an error is returned.
-func (ts *TaskStore) DeleteTask(id int) error {
+// GetTasksByIdRange returns the tasks whose id is between minId and maxId, both included,
+// sorted by id, so that the whole store can be walked in chunks of ids. Like GetAllTasks,
+// it leaves out archived tasks. If minId is greater than maxId, an error is returned.
+func (ts *TaskStore) GetTasksByIdRange(minId, maxId int) ([]Task, error) {
+	if minId > maxId {
+		return nil, fmt.Errorf("invalid id range: min %d is greater than max %d", minId, maxId)
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := []Task{}
+
+	// Look the ids up one by one when there are fewer of them than tasks, the difference
+	// cannot overflow as a uint. The loop stops at maxId before incrementing, which could
+	// otherwise overflow.
+	if uint(maxId-minId) < uint(ts.count) {
+		for id := minId; ; id++ {
+			if task, ok := ts.backend.Get(id); ok && !task.Archived {
+				tasks = append(tasks, copyTask(task))
+			}
+			if id == maxId {
+				return tasks, nil
+			}
+		}
+	}
+
+	for _, task := range ts.backend.All() {
+		if task.Id >= minId && task.Id <= maxId && !task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks, nil
+}
+
+// GetTaskOrDefault is like GetTask but returns the zero Task if no such id exists. As 0 is
+// a valid id, the result cannot tell a missing task apart from a task with id 0, use Exists
+// or GetTask when that matters.
+func (ts *TaskStore) GetTaskOrDefault(id int) Task {
+	task, _ := ts.GetTask(id)
+	return task
+}
+
+// Exists reports whether a task with the given id exists, archived or not. It is cheaper
+// than GetTask when only the presence of the task matters, as nothing is copied.
+func (ts *TaskStore) Exists(id int) bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	_, ok := ts.backend.Get(id)
+	return ok
+}
+
+// UpdateTask replaces the text, tags and due date of the task with the given id, keeping
+// its id and its other fields, its notes included, see UpdateTaskFromInput to replace those
+// as well. If no such id exists or the new values are not valid, an error is returned.
+func (ts *TaskStore) UpdateTask(id int, text string, tags []string, due time.Time) error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	if _, ok := ts.tasks[id]; ok {
-		delete(ts.tasks, id)
-		return nil
+	if ts.closed {
+		return ErrStoreClosed
 	}
 
-	return fmt.Errorf("task with id=%d not found", id)
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	if err := ts.validate(text, tags, due); err != nil {
+		return err
+	}
+
+	task.Text = text
+	task.Due = due
+
+	task.Tags = trimTags(tags)
+
+	ts.putTask(task)
+
+	return nil
 }
 
-// DeleteAllTasks deletes all tasks in the store.
-func (ts *TaskStore) DeleteAllTasks() error {
+// UpdateTaskFromInput replaces all the fields of input in the task with the given id, so
+// the notes, priority and recurrence are cleared when input leaves them empty. The id, the
+// done and archived states and the dependencies are kept. If no such id exists or the new
+// values are not valid, an error is returned.
+func (ts *TaskStore) UpdateTaskFromInput(id int, input TaskInput) error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	ts.tasks = make(map[int]Task)
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	if err := ts.validate(input.Text, input.Tags, input.Due); err != nil {
+		return err
+	}
+
+	task.Text = input.Text
+	task.Notes = input.Notes
+	task.Tags = trimTags(input.Tags)
+	task.Due = input.Due
+	task.Priority = input.Priority
+	task.Recurrence = input.Recurrence
+
+	ts.putTask(task)
+
 	return nil
+}
+
+// PatchTask applies the non-nil fields of upd to the task with the given id and returns
+// the resulting task. If no such id exists or the patched task is not valid, an error is
+// returned.
+func (ts *TaskStore) PatchTask(id int, upd TaskUpdate) (Task, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return Task{}, ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return Task{}, taskNotFound(id)
+	}
+
+	return ts.patchTask(task, upd)
+}
+
+// UpdateTaskIfVersion is like PatchTask but only applies upd if the version of the task is
+// still expectedVersion, otherwise an error wrapping ErrVersionConflict is returned.
+func (ts *TaskStore) UpdateTaskIfVersion(id int, expectedVersion int, upd TaskUpdate) (Task, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return Task{}, ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return Task{}, taskNotFound(id)
+	}
+
+	if task.Version != expectedVersion {
+		return Task{}, fmt.Errorf("task with id=%d is at version %d, expected %d: %w", id, task.Version, expectedVersion, ErrVersionConflict)
+	}
+
+	return ts.patchTask(task, upd)
+}
+
+// PatchTasks applies the non-nil fields of upd to every task with one of the given ids, at
+// once, and returns how many tasks were patched along with the ids that do not exist. A
+// task whose id is given twice is patched once. If any patched task is not valid, an error
+// is returned and no task is patched.
+func (ts *TaskStore) PatchTasks(ids []int, upd TaskUpdate) (patched int, missing []int, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0, nil, ErrStoreClosed
+	}
+
+	tasks := make([]Task, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+
+	for _, id := range ids {
+		task, ok := ts.backend.Get(id)
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		task, err := ts.applyUpdate(task, upd)
+		if err != nil {
+			return 0, nil, fmt.Errorf("task with id=%d: %w", id, err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	for _, task := range tasks {
+		ts.putTask(task)
+	}
+
+	return len(tasks), missing, nil
+}
+
+// patchTask applies upd to task and stores the result, the caller must hold the lock.
+func (ts *TaskStore) patchTask(task Task, upd TaskUpdate) (Task, error) {
+	task, err := ts.applyUpdate(task, upd)
+	if err != nil {
+		return Task{}, err
+	}
+
+	return copyTask(ts.putTask(task)), nil
+}
+
+// applyUpdate returns task with upd applied, or an error if the result is not valid, the
+// caller must hold the lock.
+func (ts *TaskStore) applyUpdate(task Task, upd TaskUpdate) (Task, error) {
+	if upd.Text != nil {
+		task.Text = *upd.Text
+	}
+
+	if upd.Notes != nil {
+		task.Notes = *upd.Notes
+	}
+
+	if upd.Tags != nil {
+		task.Tags = trimTags(*upd.Tags)
+	}
+
+	if upd.Due != nil {
+		task.Due = *upd.Due
+	}
+
+	if upd.Priority != nil {
+		task.Priority = *upd.Priority
+	}
+
+	if upd.Recurrence != nil {
+		task.Recurrence = *upd.Recurrence
+	}
+
+	if err := ts.validate(task.Text, task.Tags, task.Due); err != nil {
+		return Task{}, err
+	}
+
+	return task, nil
+}
+
+// GetAllTasks returns all the tasks in the store that are not archived, sorted by id in
+// ascending order.
+func (ts *TaskStore) GetAllTasks() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return ts.activeTasks()
+
+}
+
+// ForEach calls fn for every task in the store that is not archived, in arbitrary order,
+// until fn returns false.
+// fn runs while the store's read lock is held, so it must not call any other method of the
+// store, doing so may deadlock.
+func (ts *TaskStore) ForEach(fn func(Task) bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && !fn(copyTask(task)) {
+			return
+		}
+	}
+}
+
+// Filter returns the tasks that are not archived and for which pred returns true, sorted by
+// id. As with ForEach, pred runs while the store's read lock is held, so it must not call
+// any other method of the store, doing so may deadlock.
+func (ts *TaskStore) Filter(pred func(Task) bool) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived {
+			continue
+		}
+
+		// pred gets its own copy, so it cannot change the tags of the store either.
+		if task := copyTask(task); pred(task) {
+			tasks = append(tasks, task)
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksPage returns at most limit tasks starting at offset, ordered by id, along with
+// the total number of tasks in the store, archived tasks excluded. An offset past the end
+// yields an empty page.
+func (ts *TaskStore) GetTasksPage(offset, limit int) ([]Task, int, error) {
+	if err := checkPage(offset, limit); err != nil {
+		return nil, 0, err
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	page, total := pageOf(ts.activeTasks(), offset, limit)
+	return page, total, nil
+}
+
+// GetTasksAfter returns at most limit tasks whose id is greater than cursorId, sorted by id,
+// along with the cursor to pass to get the next page, the id of the last returned task, or
+// cursorId itself if none was returned. Unlike offsets, cursors are not shifted by tasks
+// created or deleted between pages. Ids are never negative, unless the store was created
+// with a negative start id, so a cursor of -1 starts from the first task. Archived tasks
+// are skipped. If limit is not positive, an error is returned.
+func (ts *TaskStore) GetTasksAfter(cursorId int, limit int) ([]Task, int, error) {
+	if err := checkPage(0, limit); err != nil {
+		return nil, 0, err
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && task.Id > cursorId {
+			tasks = append(tasks, task)
+		}
+	}
+
+	sortById(tasks)
+
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+	if len(tasks) == 0 {
+		return tasks, cursorId, nil
+	}
+
+	for i, task := range tasks {
+		tasks[i] = copyTask(task)
+	}
+
+	return tasks, tasks[len(tasks)-1].Id, nil
+}
+
+// SetTaskDone marks the task with the given id as done or not done. If no such id exists,
+// an error is returned.
+func (ts *TaskStore) SetTaskDone(id int, done bool) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	task.Done = done
+	ts.putTask(task)
+
+	return nil
+}
+
+// Touch bumps the version and modification time of the task with the given id without
+// changing anything else, which tells the subscribers and GetTasksModifiedSince that the
+// task was refreshed. If no such id exists, an error is returned.
+func (ts *TaskStore) Touch(id int) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	ts.putTask(task)
+
+	return nil
+}
+
+// SetDoneByTag marks every task that has the given tag, archived ones included, as done or
+// not done and returns how many tasks were changed. Tasks already in the wanted state are
+// left untouched.
+func (ts *TaskStore) SetDoneByTag(tag string, done bool) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0
+	}
+
+	// putTask edits the index entry, so iterate over a copy of it.
+	ids := append([]int(nil), ts.tagIndex[tag]...)
+	changed := 0
+
+	for _, id := range ids {
+		task := ts.task(id)
+		if task.Done == done {
+			continue
+		}
+
+		task.Done = done
+		ts.putTask(task)
+		changed++
+	}
+
+	return changed
+}
+
+// RescheduleTask changes the due date of the task with the given id, the zero time clears
+// it. If no such id exists or the due date is not valid, an error is returned.
+func (ts *TaskStore) RescheduleTask(id int, due time.Time) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	if err := ts.validate(task.Text, task.Tags, due); err != nil {
+		return err
+	}
+
+	task.Due = due
+	ts.putTask(task)
+
+	return nil
+}
+
+// AddTagToTask adds tag, trimmed of surrounding white space, to the task with the given id,
+// unless the task already has it. If no such id exists or the tag is not valid, see
+// ValidateTag, an error is returned.
+func (ts *TaskStore) AddTagToTask(id int, tag string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	if err := ValidateTag(tag); err != nil {
+		return fmt.Errorf("cannot add tag to task with id=%d: %w", id, err)
+	}
+	tag = strings.TrimSpace(tag)
+
+	if hasTag(task.Tags, tag) {
+		return nil
+	}
+
+	if ts.maxTags > 0 && len(task.Tags) >= ts.maxTags {
+		return fmt.Errorf("task with id=%d already has the maximum of %d tags: %w", id, ts.maxTags, ErrTooManyTags)
+	}
+
+	tags := make([]string, len(task.Tags), len(task.Tags)+1)
+	copy(tags, task.Tags)
+	task.Tags = append(tags, tag)
+
+	ts.putTask(task)
+
+	return nil
+}
+
+// RemoveTagFromTask removes tag, trimmed of surrounding white space as in AddTagToTask, from
+// the task with the given id. If the task does not have it, nothing changes and it is not an
+// error. If no such id exists, an error is returned.
+func (ts *TaskStore) RemoveTagFromTask(id int, tag string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	tag = strings.TrimSpace(tag)
+	if !hasTag(task.Tags, tag) {
+		return nil
+	}
+
+	tags := make([]string, 0, len(task.Tags))
+	for _, taskTag := range task.Tags {
+		if taskTag != tag {
+			tags = append(tags, taskTag)
+		}
+	}
+	task.Tags = tags
+
+	ts.putTask(task)
+
+	return nil
+}
+
+// ReplaceTags sets the tags of the task with the given id to a copy of tags, without
+// duplicates, an empty slice clears them. If no such id exists or the tags are not valid,
+// an error is returned.
+func (ts *TaskStore) ReplaceTags(id int, tags []string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	unique := make([]string, 0, len(tags))
+	for _, tag := range trimTags(tags) {
+		if !hasTag(unique, tag) {
+			unique = append(unique, tag)
+		}
+	}
+
+	if err := ts.validate(task.Text, unique, task.Due); err != nil {
+		return err
+	}
+
+	task.Tags = unique
+	ts.putTask(task)
+
+	return nil
+}
+
+// RenameTag replaces oldTag with newTag on every task that has it, archived ones included,
+// and returns how many tasks were changed. A task that already has newTag just loses
+// oldTag, so it never ends up with newTag twice. Both tags are trimmed of surrounding white
+// space as in AddTagToTask. If newTag is not valid, see ValidateTag, an error is returned
+// and nothing is changed.
+func (ts *TaskStore) RenameTag(oldTag, newTag string) (int, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0, ErrStoreClosed
+	}
+
+	if err := ValidateTag(newTag); err != nil {
+		return 0, fmt.Errorf("cannot rename tag %q: %w", oldTag, err)
+	}
+
+	oldTag, newTag = strings.TrimSpace(oldTag), strings.TrimSpace(newTag)
+	if oldTag == newTag {
+		return 0, nil
+	}
+
+	// putTask edits the index entry, so iterate over a copy of it.
+	ids := append([]int(nil), ts.tagIndex[oldTag]...)
+
+	for _, id := range ids {
+		task := ts.task(id)
+		hasNew := hasTag(task.Tags, newTag)
+
+		tags := make([]string, 0, len(task.Tags))
+		for _, tag := range task.Tags {
+			if tag != oldTag {
+				tags = append(tags, tag)
+			} else if !hasNew {
+				tags = append(tags, newTag)
+				hasNew = true
+			}
+		}
+		task.Tags = tags
+
+		ts.putTask(task)
+	}
+
+	return len(ids), nil
+}
+
+// ArchiveTask archives the task with the given id. Archived tasks are kept in the store but
+// are left out of every query except GetTask and GetArchivedTasks. If no such id exists, an
+// error is returned.
+func (ts *TaskStore) ArchiveTask(id int) error {
+	return ts.setArchived(id, true)
+}
+
+// UnarchiveTask makes an archived task with the given id visible again. If no such id
+// exists, an error is returned.
+func (ts *TaskStore) UnarchiveTask(id int) error {
+	return ts.setArchived(id, false)
+}
+
+// setArchived sets the archived flag of the task with the given id.
+func (ts *TaskStore) setArchived(id int, archived bool) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	task, ok := ts.backend.Get(id)
+	if !ok {
+		return taskNotFound(id)
+	}
+
+	task.Archived = archived
+	ts.putTask(task)
+
+	return nil
+}
+
+// GetArchivedTasks returns all the archived tasks, sorted by id.
+func (ts *TaskStore) GetArchivedTasks() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0, ts.archived)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetIncompleteTasks returns all the tasks that are not done, sorted by id.
+func (ts *TaskStore) GetIncompleteTasks() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if !task.Done && !task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksByPriority returns all the tasks sorted by priority, most urgent first. Tasks with
+// the same priority are sorted by id.
+func (ts *TaskStore) GetTasksByPriority() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := ts.activeTasks()
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Priority > tasks[j].Priority
+	})
+
+	return tasks
+}
+
+// GetTasksSortedByDue returns all the tasks sorted by due date, soonest first if ascending
+// is true and latest first otherwise. Tasks without a due date always come last, sorted by
+// id, whatever the direction.
+func (ts *TaskStore) GetTasksSortedByDue(ascending bool) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := ts.activeTasks()
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		di, dj := tasks[i].Due, tasks[j].Due
+		if di.IsZero() || dj.IsZero() {
+			return !di.IsZero() && dj.IsZero()
+		}
+		if ascending {
+			return di.Before(dj)
+		}
+		return di.After(dj)
+	})
+
+	return tasks
+}
+
+// GetTasksByTag returns all the tasks that have the given tag, sorted by id.
+func (ts *TaskStore) GetTasksByTag(tag string) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return ts.tasksWithTag(tag)
+}
+
+// GetTasksByTagPaged is like GetTasksPage but only considers the tasks that have the given
+// tag, the returned total is the number of such tasks.
+func (ts *TaskStore) GetTasksByTagPaged(tag string, offset, limit int) ([]Task, int, error) {
+	if err := checkPage(offset, limit); err != nil {
+		return nil, 0, err
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	page, total := pageOf(ts.tasksWithTag(tag), offset, limit)
+	return page, total, nil
+}
+
+// tasksWithTag returns the tasks that have the given tag and are not archived, sorted by id,
+// the caller must hold the lock.
+func (ts *TaskStore) tasksWithTag(tag string) []Task {
+	ids := ts.tagIndex[tag]
+	tasks := make([]Task, 0, len(ids))
+
+	for _, id := range ids {
+		if task := ts.task(id); !task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksByTagPrefix returns the tasks that have at least one tag starting with prefix,
+// such as the tags "project/alpha" and "project/beta" for the prefix "project/", sorted by
+// id. A task with several such tags is returned once. An empty prefix matches every task,
+// those without tags included.
+func (ts *TaskStore) GetTasksByTagPrefix(prefix string) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if prefix == "" {
+		return ts.activeTasks()
+	}
+
+	seen := make(map[int]bool)
+	tasks := make([]Task, 0)
+
+	for tag, ids := range ts.tagIndex {
+		if !strings.HasPrefix(tag, prefix) {
+			continue
+		}
+
+		for _, id := range ids {
+			if task := ts.task(id); !task.Archived && !seen[id] {
+				seen[id] = true
+				tasks = append(tasks, copyTask(task))
+			}
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksByTags returns the tasks that have all of the given tags if matchAll is true, or
+// at least one of them otherwise, sorted by id. An empty tags slice matches every task.
+func (ts *TaskStore) GetTasksByTags(tags []string, matchAll bool) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if len(tags) == 0 {
+		return ts.activeTasks()
+	}
+
+	// Give every distinct query tag an index, so the tags of a task can be matched with a
+	// single map lookup each.
+	index := make(map[string]int, len(tags))
+	for _, tag := range tags {
+		if _, ok := index[tag]; !ok {
+			index[tag] = len(index)
+		}
+	}
+
+	matched := make([]bool, len(index))
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived {
+			continue
+		}
+
+		for i := range matched {
+			matched[i] = false
+		}
+
+		count := 0
+		for _, taskTag := range task.Tags {
+			if i, ok := index[taskTag]; ok && !matched[i] {
+				matched[i] = true
+				count++
+			}
+		}
+
+		if (matchAll && count == len(index)) || (!matchAll && count > 0) {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksByTagsExcluding returns the tasks that have all of the include tags and none of
+// the exclude tags, sorted by id. A task with both an include and an exclude tag is left
+// out. An empty include slice matches every task, and an empty exclude slice excludes none.
+func (ts *TaskStore) GetTasksByTagsExcluding(include, exclude []string) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	// As in GetTasksByTags, give every distinct include tag an index.
+	index := make(map[string]int, len(include))
+	for _, tag := range include {
+		if _, ok := index[tag]; !ok {
+			index[tag] = len(index)
+		}
+	}
+
+	excluded := make(map[string]bool, len(exclude))
+	for _, tag := range exclude {
+		excluded[tag] = true
+	}
+
+	matched := make([]bool, len(index))
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived {
+			continue
+		}
+
+		for i := range matched {
+			matched[i] = false
+		}
+
+		count := 0
+		keep := true
+		for _, taskTag := range task.Tags {
+			if excluded[taskTag] {
+				keep = false
+				break
+			}
+			if i, ok := index[taskTag]; ok && !matched[i] {
+				matched[i] = true
+				count++
+			}
+		}
+
+		if keep && count == len(index) {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// SearchByTagsRanked returns the tasks that have at least one of the given tags, sorted by
+// the number of distinct given tags they have, most first, then by id.
+func (ts *TaskStore) SearchByTagsRanked(tags []string) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	// Count the matches through the tag index, once per distinct query tag.
+	counts := make(map[int]int)
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+
+		for _, id := range ts.tagIndex[tag] {
+			counts[id]++
+		}
+	}
+
+	tasks := make([]Task, 0, len(counts))
+	for id := range counts {
+		if task := ts.task(id); !task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		ci, cj := counts[tasks[i].Id], counts[tasks[j].Id]
+		if ci != cj {
+			return ci > cj
+		}
+		return tasks[i].Id < tasks[j].Id
+	})
+
+	return tasks
+}
+
+// GroupByTag returns, for every tag, the tasks that have it, sorted by id. A task with
+// several tags appears in the group of each of them, and the tasks without tags are
+// grouped under the empty tag "".
+func (ts *TaskStore) GroupByTag() map[string][]Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	groups := make(map[string][]Task)
+
+	for _, task := range ts.sortedTasks() {
+		if task.Archived {
+			continue
+		}
+
+		if len(task.Tags) == 0 {
+			groups[""] = append(groups[""], copyTask(task))
+		}
+
+		for i, tag := range task.Tags {
+			if !hasTag(task.Tags[:i], tag) {
+				groups[tag] = append(groups[tag], copyTask(task))
+			}
+		}
+	}
+
+	return groups
+}
+
+// GetAllTags returns the distinct tags used by the tasks in the store, sorted alphabetically.
+func (ts *TaskStore) GetAllTags() []string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	n := 0
+	for _, task := range ts.backend.All() {
+		if !task.Archived {
+			n += len(task.Tags)
+		}
+	}
+
+	tags := make([]string, 0, n)
+	for _, task := range ts.backend.All() {
+		if !task.Archived {
+			tags = append(tags, task.Tags...)
+		}
+	}
+
+	sort.Strings(tags)
+
+	// Compact the sorted tags in place, keeping the first of every run of duplicates.
+	unique := tags[:0]
+	for _, tag := range tags {
+		if len(unique) == 0 || tag != unique[len(unique)-1] {
+			unique = append(unique, tag)
+		}
+	}
+
+	return unique
+}
+
+// GetTasksByDueDate returns all the tasks that have the given due date, sorted by id.
+func (ts *TaskStore) GetTasksByDueDate(year int, month time.Month, day int) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	ids := ts.dueIndex[dayKey{year: year, month: month, day: day}]
+	tasks := make([]Task, 0, len(ids))
+
+	for _, id := range ids {
+		if task := ts.task(id); !task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksDueToday returns the tasks due today, sorted by due date. Today is the date of the
+// current time of the store clock, see SetClock, in the location of that time, which is the
+// local time zone for the default clock. As with GetTasksByDueDate, the date of every task
+// is read in the location of its own due date.
+func (ts *TaskStore) GetTasksDueToday() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	ids := ts.dueIndex[dueDay(ts.now())]
+	tasks := make([]Task, 0, len(ids))
+
+	for _, id := range ids {
+		if task := ts.task(id); !task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortByDue(tasks)
+
+	return tasks
+}
+
+// GetTasksByWeekday returns the tasks due on the given day of the week, sorted by due date.
+// As with GetTasksByDueDate, the day of every task is read in the location of its own due
+// date. Tasks without a due date are skipped.
+func (ts *TaskStore) GetTasksByWeekday(day time.Weekday) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && !task.Due.IsZero() && task.Due.Weekday() == day {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortByDue(tasks)
+
+	return tasks
+}
+
+// GetTasksWithoutDue returns all the tasks that have no due date, sorted by id.
+func (ts *TaskStore) GetTasksWithoutDue() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && task.Due.IsZero() {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetOverdueTasks returns all the tasks that were due before the current time of the store
+// clock, sorted by due date so the most overdue task comes first. Tasks without a due date
+// are skipped.
+func (ts *TaskStore) GetOverdueTasks() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	now := ts.now()
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && !task.Due.IsZero() && task.Due.Before(now) {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortByDue(tasks)
+
+	return tasks
+}
+
+// GetOpenOverdueTasks returns all the tasks that are not done and were due before the
+// current time of the store clock, sorted by due date so the most overdue task comes first.
+// Tasks without a due date are skipped.
+func (ts *TaskStore) GetOpenOverdueTasks() []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	now := ts.now()
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if !task.Done && !task.Archived && !task.Due.IsZero() && task.Due.Before(now) {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortByDue(tasks)
+
+	return tasks
+}
+
+// GetNextDueTask returns the task that is not done with the earliest due date at or after
+// now, the one with the lowest id among ties. It returns false if there is no such task.
+func (ts *TaskStore) GetNextDueTask(now time.Time) (Task, bool) {
+	return ts.earliestDue(func(due time.Time) bool { return !due.Before(now) })
+}
+
+// GetMostOverdueTask returns the task that is not done with the earliest due date before
+// now, the one with the lowest id among ties. It returns false if there is no such task.
+func (ts *TaskStore) GetMostOverdueTask(now time.Time) (Task, bool) {
+	return ts.earliestDue(func(due time.Time) bool { return due.Before(now) })
+}
+
+// earliestDue returns the task that is not done with the earliest due date accepted by
+// match, the one with the lowest id among ties.
+func (ts *TaskStore) earliestDue(match func(due time.Time) bool) (Task, bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	var best Task
+	found := false
+
+	for _, task := range ts.backend.All() {
+		if task.Done || task.Archived || task.Due.IsZero() || !match(task.Due) {
+			continue
+		}
+
+		if !found || task.Due.Before(best.Due) || (task.Due.Equal(best.Due) && task.Id < best.Id) {
+			best = task
+			found = true
+		}
+	}
+
+	if !found {
+		return Task{}, false
+	}
+
+	return copyTask(best), true
+}
+
+// GetTasksDueWithin returns all the tasks due between the current time of the store clock
+// and window later, both bounds inclusive, sorted by due date. Tasks without a due date are
+// skipped. If window is negative, an error is returned.
+func (ts *TaskStore) GetTasksDueWithin(window time.Duration) ([]Task, error) {
+	if window < 0 {
+		return nil, fmt.Errorf("window must not be negative, got %v", window)
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	now := ts.now()
+
+	return ts.tasksDueBetween(now, now.Add(window)), nil
+}
+
+// GetTasksCreatedBetween returns all the tasks created between start and end, both bounds
+// inclusive, sorted by id.
+func (ts *TaskStore) GetTasksCreatedBetween(start, end time.Time) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived || task.CreatedAt.Before(start) || task.CreatedAt.After(end) {
+			continue
+		}
+		tasks = append(tasks, copyTask(task))
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksModifiedSince returns all the tasks changed after t, archived ones included so
+// that archiving shows up as a change, sorted by modification time. Deleted tasks cannot be
+// reported, use Subscribe to be told about them.
+func (ts *TaskStore) GetTasksModifiedSince(t time.Time) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.UpdatedAt.After(t) {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].UpdatedAt.Equal(tasks[j].UpdatedAt) {
+			return tasks[i].UpdatedAt.Before(tasks[j].UpdatedAt)
+		}
+		return tasks[i].Id < tasks[j].Id
+	})
+
+	return tasks
+}
+
+// GetTasksByDueRange returns all the tasks due between start and end, both bounds inclusive,
+// sorted by due date. Tasks without a due date are skipped.
+func (ts *TaskStore) GetTasksByDueRange(start, end time.Time) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return ts.tasksDueBetween(start, end)
+}
+
+// tasksDueBetween returns the tasks due between start and end, both bounds inclusive,
+// sorted by due date, the caller must hold the lock.
+func (ts *TaskStore) tasksDueBetween(start, end time.Time) []Task {
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived || task.Due.IsZero() || task.Due.Before(start) || task.Due.After(end) {
+			continue
+		}
+		tasks = append(tasks, copyTask(task))
+	}
+
+	sortByDue(tasks)
+
+	return tasks
+}
+
+// SearchTasks returns all the tasks whose text or notes contain query, ignoring case, sorted
+// by id. An empty query matches every task.
+func (ts *TaskStore) SearchTasks(query string) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if task.Archived {
+			continue
+		}
+
+		if strings.Contains(strings.ToLower(task.Text), query) || strings.Contains(strings.ToLower(task.Notes), query) {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// GetTasksByExactText returns all the tasks whose text is exactly text, case included,
+// sorted by id. Texts are not unique, so several tasks may be returned.
+func (ts *TaskStore) GetTasksByExactText(text string) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	tasks := make([]Task, 0)
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived && task.Text == text {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// SearchByPrefix returns all the tasks whose text starts with prefix, ignoring case, sorted
+// by text, also ignoring case, and then by id. An empty prefix matches every task.
+func (ts *TaskStore) SearchByPrefix(prefix string) []Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	prefix = strings.ToLower(prefix)
+	tasks := make([]Task, 0)
+	// lower holds the lower-cased text of every matching task, keyed by id, to sort by.
+	lower := make(map[int]string)
+
+	for _, task := range ts.backend.All() {
+		if text := strings.ToLower(task.Text); !task.Archived && strings.HasPrefix(text, prefix) {
+			tasks = append(tasks, copyTask(task))
+			lower[task.Id] = text
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if li, lj := lower[tasks[i].Id], lower[tasks[j].Id]; li != lj {
+			return li < lj
+		}
+		return tasks[i].Id < tasks[j].Id
+	})
+
+	return tasks
+}
+
+// Count returns the number of tasks in the store that are not archived.
+func (ts *TaskStore) Count() int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return ts.count - ts.archived
+}
+
+// Len returns the number of tasks held by the store. Unlike Count, archived tasks are
+// included, which makes it the right measure of how big the store has grown.
+func (ts *TaskStore) Len() int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return ts.count
+}
+
+// CountByTag returns the number of tasks that have the given tag and are not archived.
+func (ts *TaskStore) CountByTag(tag string) int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	count := 0
+
+	for _, id := range ts.tagIndex[tag] {
+		if !ts.task(id).Archived {
+			count++
+		}
+	}
+
+	return count
+}
+
+// DeleteTask deletes the task with the given id. If no such id exists, an error is returned.
+func (ts *TaskStore) DeleteTask(id int) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	if _, ok := ts.backend.Get(id); ok {
+		ts.removeTask(id)
+		return nil
+	}
+
+	return taskNotFound(id)
+}
+
+// DeleteTasks deletes the tasks with the given ids, all at once. It returns the number of
+// deleted tasks and the ids that do not exist.
+func (ts *TaskStore) DeleteTasks(ids []int) (deleted int, missing []int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0, nil
+	}
+
+	for _, id := range ids {
+		if _, ok := ts.backend.Get(id); ok {
+			ts.removeTask(id)
+			deleted++
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return deleted, missing
+}
+
+// DeleteTasksByTag deletes all the tasks that have the given tag, archived ones included,
+// and returns how many were deleted.
+func (ts *TaskStore) DeleteTasksByTag(tag string) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0
+	}
+
+	// removeTask edits the index entry, so iterate over a copy of it.
+	ids := append([]int(nil), ts.tagIndex[tag]...)
+
+	for _, id := range ids {
+		ts.removeTask(id)
+	}
+
+	return len(ids)
+}
+
+// DeleteTasksDueBefore deletes all the tasks due before cutoff, archived ones included, and
+// returns how many were deleted. Tasks without a due date are kept.
+func (ts *TaskStore) DeleteTasksDueBefore(cutoff time.Time) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return 0
+	}
+
+	deleted := 0
+
+	for _, task := range ts.backend.All() {
+		if !task.Due.IsZero() && task.Due.Before(cutoff) {
+			ts.removeTask(task.Id)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// DeleteAllTasks deletes all tasks in the store.
+func (ts *TaskStore) DeleteAllTasks() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return ErrStoreClosed
+	}
+
+	for _, task := range ts.backend.All() {
+		task := task
+		ts.record(EventDeleted, task.Id, &task, nil)
+		ts.emit(EventDeleted, task.Id)
+	}
+
+	ts.setTasks(nil)
+	ts.dropKeys()
+	return nil
+
+}
+
+// activeTasks returns the tasks in the store that are not archived, sorted by id, the caller
+// must hold the lock.
+func (ts *TaskStore) activeTasks() []Task {
+	tasks := make([]Task, 0, ts.count-ts.archived)
+
+	for _, task := range ts.backend.All() {
+		if !task.Archived {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+
+	sortById(tasks)
+
+	return tasks
+}
+
+// sortedTasks returns all the tasks in the store, archived ones included, sorted by id, the
+// caller must hold the lock. Unlike the exported methods, the returned tasks share their
+// tags with the store and must not be handed out as is.
+func (ts *TaskStore) sortedTasks() []Task {
+	allTasks := make([]Task, 0, ts.count)
+
+	for _, task := range ts.backend.All() {
+		allTasks = append(allTasks, task)
+	}
+
+	sortById(allTasks)
+
+	return allTasks
+}
+
+// checkPage returns an error if offset and limit do not describe a valid page.
+func checkPage(offset, limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("limit must be positive, got %d", limit)
+	}
+
+	if offset < 0 {
+		return fmt.Errorf("offset must not be negative, got %d", offset)
+	}
+
+	return nil
+}
+
+// pageOf returns at most limit of tasks starting at offset, along with the number of tasks.
+func pageOf(tasks []Task, offset, limit int) ([]Task, int) {
+	total := len(tasks)
+	if offset >= total {
+		return []Task{}, total
+	}
+
+	end := offset + limit
+	if end > total || end < offset {
+		end = total
+	}
+
+	return tasks[offset:end], total
+}
+
+// hasTag reports whether tag is one of tags.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// SetClock makes the store use fn to tell the current time instead of time.Now, which is
+// mostly useful to freeze the time in tests.
+func (ts *TaskStore) SetClock(fn func() time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.clock = fn
+}
+
+// now returns the current time according to the clock of the store, the caller must hold
+// the lock.
+func (ts *TaskStore) now() time.Time {
+	if ts.clock == nil {
+		return time.Now()
+	}
+	return ts.clock()
+}
+
+// taskNotFound returns an error wrapping ErrTaskNotFound for the given id.
+func taskNotFound(id int) error {
+	return fmt.Errorf("task with id=%d does not exist: %w", id, ErrTaskNotFound)
+}
+
+// sortByDue sorts tasks in place by due date, ascending, ties are broken by id.
+func sortByDue(tasks []Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].Due.Equal(tasks[j].Due) {
+			return tasks[i].Due.Before(tasks[j].Due)
+		}
+		return tasks[i].Id < tasks[j].Id
+	})
+}
+
+// sortById sorts tasks in place by id, ascending.
+func sortById(tasks []Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Id < tasks[j].Id
+	})
+}
+
+// Snapshot returns a deep copy of all the tasks in the store, keyed by id, which can later
+// be handed to Restore.
+func (ts *TaskStore) Snapshot() map[int]Task {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	snapshot := make(map[int]Task, ts.count)
+
+	for _, task := range ts.backend.All() {
+		snapshot[task.Id] = copyTask(task)
+	}
+
+	return snapshot
+}
+
+// Restore replaces all the tasks in the store with a deep copy of snapshot. The next id is
+// moved past the largest restored id but never backwards, so ids handed out after the
+// snapshot was taken are not reused.
+func (ts *TaskStore) Restore(snapshot map[int]Task) {
+	tasks := make(map[int]Task, len(snapshot))
+
+	for id, task := range snapshot {
+		task.Id = id
+		tasks[id] = copyTask(task)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return
+	}
+
+	ts.setTasks(tasks)
+	ts.dropKeys()
+
+	for id := range tasks {
+		if id >= ts.nextId {
+			ts.nextId = id + 1
+		}
+	}
+
+	ts.record(EventReset, -1, nil, nil)
+	ts.emit(EventReset, -1)
+}
+
+// WithLock runs fn while holding the write lock of the store, so that fn can read and change
+// the tasks in several steps that no other goroutine sees halfway. fn is given a deep copy
+// of the tasks keyed by id, which it may change freely. Once fn returns, the changes are
+// applied as if made one by one: every task fn added or changed is stored with the id of
+// its key and its version and modification time bumped, every task fn deleted is deleted,
+// and the subscribers get one event per change, while the tasks fn left alone keep their
+// versions and idempotency keys. The next id is moved past the largest id but never
+// backwards. The sharp edges are that fn must not call any method of the store, which
+// would deadlock, and must not keep the map or its tags after returning. The whole store is
+// copied for fn, so WithLock is slow on large stores. After Close, fn is not called.
+func (ts *TaskStore) WithLock(fn func(tasks map[int]Task)) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return
+	}
+
+	working := make(map[int]Task, ts.count)
+	for _, task := range ts.backend.All() {
+		working[task.Id] = copyTask(task)
+	}
+
+	fn(working)
+
+	for _, old := range ts.backend.All() {
+		if _, ok := working[old.Id]; !ok {
+			ts.removeTask(old.Id)
+		}
+	}
+
+	// Apply the changes in the order of the ids, so the events come in a stable order.
+	ids := make([]int, 0, len(working))
+	for id := range working {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		task := working[id]
+		task.Id = id
+
+		if old, ok := ts.backend.Get(id); ok {
+			// putTask maintains these, so changing them alone changes nothing.
+			task.Version, task.UpdatedAt = old.Version, old.UpdatedAt
+			if sameTask(task, old) {
+				continue
+			}
+		}
+
+		ts.putTask(copyTask(task))
+
+		if id >= ts.nextId {
+			ts.nextId = id + 1
+		}
+	}
+}
+
+// ReplaceAll replaces all the tasks in the store with a deep copy of tasks, keeping their
+// ids, at once. Unlike Restore, the next id is set just past the largest id in tasks, or to
+// 0 if tasks is empty, even if it moves backwards. If two tasks have the same id, an error
+// is returned and the store is left unchanged.
+func (ts *TaskStore) ReplaceAll(tasks []Task) error {
+	return ts.load(storeData{Tasks: tasks})
+}
+
+// CompactIds renumbers the tasks to the ids 0 to n-1, keeping their order by id, and
+// returns a map from every old id to its new id. This breaks every reference to the old
+// ids held outside the store, callers must use the returned map to migrate them.
+func (ts *TaskStore) CompactIds() map[int]int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return map[int]int{}
+	}
+
+	sorted := ts.sortedTasks()
+	mapping := make(map[int]int, len(sorted))
+	tasks := make(map[int]Task, len(sorted))
+
+	for newId, task := range sorted {
+		mapping[task.Id] = newId
+	}
+
+	for _, task := range sorted {
+		task.Id = mapping[task.Id]
+		task.DependsOn = remapDeps(task.DependsOn, mapping)
+		tasks[task.Id] = task
+	}
+
+	ts.setTasks(tasks)
+	ts.nextId = len(tasks)
+	ts.remapKeys(mapping)
+
+	ts.record(EventReset, -1, nil, nil)
+	ts.emit(EventReset, -1)
+
+	return mapping
+}
+
+// copyTask returns a copy of task that does not share its tags or dependencies with the
+// original.
+func copyTask(task Task) Task {
+	tags := make([]string, len(task.Tags))
+	copy(tags, task.Tags)
+	task.Tags = tags
+
+	if task.DependsOn != nil {
+		task.DependsOn = append([]int(nil), task.DependsOn...)
+	}
 
+	return task
 }
@@ -0,0 +1,1080 @@
+package taskstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mustCreate creates a task in ts and fails the test if that is not possible.
+func mustCreate(t testing.TB, ts *TaskStore, text string, tags []string, due time.Time) int {
+	t.Helper()
+
+	id, err := ts.CreateTask(text, tags, due)
+	if err != nil {
+		t.Fatalf("CreateTask(%q, %v, %v): %v", text, tags, due, err)
+	}
+	return id
+}
+
+// taskIds returns the ids of tasks, in order.
+func taskIds(tasks []Task) []int {
+	ids := make([]int, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.Id
+	}
+	return ids
+}
+
+// checkIds fails the test if tasks do not have exactly the ids want, in order.
+func checkIds(t *testing.T, tasks []Task, want ...int) {
+	t.Helper()
+
+	got := taskIds(tasks)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got tasks %v, want %v", got, want)
+	}
+}
+
+func TestWriterBlocksReaders(t *testing.T) {
+	ts := New()
+	mustCreate(t, ts, "task", nil, time.Time{})
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	go ts.WithLock(func(tasks map[int]Task) {
+		close(locked)
+		<-release
+	})
+	<-locked
+
+	read := make(chan int)
+	go func() {
+		read <- len(ts.GetAllTasks())
+	}()
+
+	select {
+	case <-read:
+		t.Fatal("GetAllTasks returned while the write lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case n := <-read:
+		if n != 1 {
+			t.Errorf("got %d tasks, want 1", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetAllTasks did not return once the write lock was released")
+	}
+}
+
+func BenchmarkConcurrentReaders(b *testing.B) {
+	ts := New()
+	for i := 0; i < 100; i++ {
+		mustCreate(b, ts, fmt.Sprintf("task %d", i), []string{"tag"}, time.Time{})
+	}
+
+	for _, readers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("readers=%d", readers), func(b *testing.B) {
+			var wg sync.WaitGroup
+			perReader := b.N/readers + 1
+
+			b.ResetTimer()
+			for r := 0; r < readers; r++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < perReader; i++ {
+						ts.GetTasksByTag("tag")
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestAddAndRemoveTag(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "task", []string{"a"}, time.Time{})
+
+	if err := ts.AddTagToTask(id, " urgent "); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AddTagToTask(id, "urgent"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[a urgent]" {
+		t.Errorf("tags after adding are %q, want [a urgent]", task.Tags)
+	}
+
+	if err := ts.RemoveTagFromTask(id, " urgent "); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[a]" {
+		t.Errorf("tags after removing are %q, want [a]", task.Tags)
+	}
+
+	if err := ts.AddTagToTask(-1, "a"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("AddTagToTask on a missing id returned %v, want ErrTaskNotFound", err)
+	}
+	if err := ts.RemoveTagFromTask(-1, "a"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("RemoveTagFromTask on a missing id returned %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestRemoveMissingTagChangesNothing(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "task", []string{"a"}, time.Time{})
+	before, _ := ts.GetTask(id)
+
+	events, unsubscribe := ts.Subscribe()
+	defer unsubscribe()
+
+	if err := ts.RemoveTagFromTask(id, "missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("removing a missing tag emitted %+v", event)
+	default:
+	}
+
+	text := "edited"
+	if _, err := ts.UpdateTaskIfVersion(id, before.Version, TaskUpdate{Text: &text}); err != nil {
+		t.Errorf("UpdateTaskIfVersion with the version from before the removal: %v", err)
+	}
+}
+
+func BenchmarkFillWithCapacity(b *testing.B) {
+	const n = 10000
+
+	for _, hint := range []int{0, n} {
+		b.Run(fmt.Sprintf("hint=%d", hint), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ts := NewWithCapacity(hint)
+				for j := 0; j < n; j++ {
+					mustCreate(b, ts, "task", nil, time.Time{})
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotIsUnaffectedByLaterChanges(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "task", []string{"a"}, time.Time{})
+	deleted := mustCreate(t, ts, "deleted", nil, time.Time{})
+
+	snapshot := ts.Snapshot()
+
+	ts.UpdateTask(id, "edited", []string{"b"}, time.Time{})
+	ts.AddTagToTask(id, "c")
+	ts.DeleteTask(deleted)
+	mustCreate(t, ts, "new", nil, time.Time{})
+
+	if len(snapshot) != 2 {
+		t.Fatalf("snapshot has %d tasks, want 2", len(snapshot))
+	}
+	if task := snapshot[id]; task.Text != "task" || fmt.Sprint(task.Tags) != "[a]" {
+		t.Errorf("snapshot task changed to %+v", task)
+	}
+	if _, ok := snapshot[deleted]; !ok {
+		t.Error("deleted task missing from the snapshot")
+	}
+
+	// Changing the snapshot does not change the store either.
+	snapshot[id].Tags[0] = "changed"
+	if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[b c]" {
+		t.Errorf("store tags changed to %q", task.Tags)
+	}
+
+	ts.Restore(snapshot)
+	if task, _ := ts.GetTask(id); task.Text != "task" || !ts.Exists(deleted) {
+		t.Errorf("Restore did not bring back the snapshot, task is %+v", task)
+	}
+}
+
+func TestReturnedTagsAreCopies(t *testing.T) {
+	ts := New()
+	due := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	id := mustCreate(t, ts, "task", []string{"a", "b"}, due)
+
+	readers := map[string]func() []Task{
+		"GetTask": func() []Task {
+			task, _ := ts.GetTask(id)
+			return []Task{task}
+		},
+		"GetAllTasks":       ts.GetAllTasks,
+		"GetTasksByTag":     func() []Task { return ts.GetTasksByTag("a") },
+		"GetTasksByDueDate": func() []Task { return ts.GetTasksByDueDate(2023, time.March, 1) },
+		"SearchTasks":       func() []Task { return ts.SearchTasks("task") },
+		"Filter":            func() []Task { return ts.Filter(func(Task) bool { return true }) },
+	}
+
+	for name, read := range readers {
+		tasks := read()
+		if len(tasks) != 1 {
+			t.Fatalf("%s returned %d tasks, want 1", name, len(tasks))
+		}
+
+		tasks[0].Tags[0] = "changed"
+		tasks[0].Tags = append(tasks[0].Tags[:1], "appended")
+
+		if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[a b]" {
+			t.Fatalf("changing the tags returned by %s changed the store to %q", name, task.Tags)
+		}
+	}
+	checkIds(t, ts.GetTasksByTag("a"), id)
+}
+
+func TestCreateNearMaxId(t *testing.T) {
+	ts := NewWithStartId(math.MaxInt - 2)
+
+	if id := mustCreate(t, ts, "first", nil, time.Time{}); id != math.MaxInt-2 {
+		t.Errorf("first task got id=%d, want %d", id, math.MaxInt-2)
+	}
+
+	_, err := ts.CreateTasks([]TaskInput{{Text: "a"}, {Text: "b"}})
+	if !errors.Is(err, ErrIdOverflow) {
+		t.Errorf("CreateTasks past math.MaxInt returned %v, want ErrIdOverflow", err)
+	}
+	if n := ts.Count(); n != 1 {
+		t.Errorf("store has %d tasks after the failed CreateTasks, want 1", n)
+	}
+
+	if id := mustCreate(t, ts, "last", nil, time.Time{}); id != math.MaxInt-1 {
+		t.Errorf("last task got id=%d, want %d", id, math.MaxInt-1)
+	}
+	if _, err := ts.CreateTask("overflow", nil, time.Time{}); !errors.Is(err, ErrIdOverflow) {
+		t.Errorf("CreateTask past math.MaxInt returned %v, want ErrIdOverflow", err)
+	}
+
+	ts.CompactIds()
+	if id := mustCreate(t, ts, "after compaction", nil, time.Time{}); id != 2 {
+		t.Errorf("task after CompactIds got id=%d, want 2", id)
+	}
+}
+
+func TestTaskMarshalJSON(t *testing.T) {
+	due := time.Date(2023, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, want := range []time.Time{{}, due} {
+		task := Task{Id: 1, Text: "task", Tags: []string{"a"}, Due: want}
+
+		b, err := json.Marshal(task)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(b, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if fields["text"] != "task" || fields["id"] != 1.0 {
+			t.Errorf("%s lost the other fields", b)
+		}
+
+		got, ok := fields["due"]
+		switch {
+		case want.IsZero() && ok:
+			t.Errorf("%s has a due date, want none", b)
+		case !want.IsZero() && got != "2023-03-01T12:30:00Z":
+			t.Errorf("%s has due %v, want 2023-03-01T12:30:00Z", b, got)
+		}
+
+		var decoded Task
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.EqualContent(task) {
+			t.Errorf("%s decoded as %+v, want %+v", b, decoded, task)
+		}
+	}
+}
+
+func TestNoMatchesMarshalAsEmptyArray(t *testing.T) {
+	ts := New()
+	mustCreate(t, ts, "task", []string{"a"}, time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	results := map[string][]Task{
+		"GetTasksByTag":      ts.GetTasksByTag("missing"),
+		"GetTasksByDueDate":  ts.GetTasksByDueDate(2024, time.January, 1),
+		"GetTasksByDueRange": ts.GetTasksByDueRange(start, start.AddDate(0, 1, 0)),
+	}
+
+	for name, tasks := range results {
+		b, err := json.Marshal(tasks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "[]" {
+			t.Errorf("%s without matches marshaled as %s, want []", name, b)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "task", nil, time.Time{})
+	deleted := mustCreate(t, ts, "deleted", nil, time.Time{})
+	archived := mustCreate(t, ts, "archived", nil, time.Time{})
+	ts.DeleteTask(deleted)
+	ts.ArchiveTask(archived)
+
+	for _, c := range []struct {
+		id   int
+		want bool
+	}{{id, true}, {archived, true}, {deleted, false}, {-1, false}, {100, false}} {
+		if got := ts.Exists(c.id); got != c.want {
+			t.Errorf("Exists(%d) = %v, want %v", c.id, got, c.want)
+		}
+	}
+}
+
+func TestGetTasksByDueRange(t *testing.T) {
+	ts := New()
+	noon := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	later := mustCreate(t, ts, "later", nil, noon.Add(time.Hour))
+	atNoon := mustCreate(t, ts, "noon", nil, noon)
+	alsoAtNoon := mustCreate(t, ts, "also noon", nil, noon)
+	mustCreate(t, ts, "no due", nil, time.Time{})
+
+	// Equal bounds match the tasks due exactly then.
+	checkIds(t, ts.GetTasksByDueRange(noon, noon), atNoon, alsoAtNoon)
+	checkIds(t, ts.GetTasksByDueRange(noon, noon.Add(time.Hour)), atNoon, alsoAtNoon, later)
+	checkIds(t, ts.GetTasksByDueRange(noon.Add(time.Nanosecond), noon.Add(time.Hour)), later)
+
+	// A range ending before it starts or holding no due date matches nothing.
+	checkIds(t, ts.GetTasksByDueRange(noon.Add(time.Hour), noon))
+	checkIds(t, ts.GetTasksByDueRange(noon.Add(time.Minute), noon.Add(time.Minute*59)))
+}
+
+func TestSearchTasksIgnoresCase(t *testing.T) {
+	ts := New()
+	milk := mustCreate(t, ts, "Buy MILK", nil, time.Time{})
+	ecole := mustCreate(t, ts, "Aller à l'École", nil, time.Time{})
+	sofia := mustCreate(t, ts, "Σοφία", nil, time.Time{})
+	mustCreate(t, ts, "unrelated", nil, time.Time{})
+
+	checkIds(t, ts.SearchTasks("milk"), milk)
+	checkIds(t, ts.SearchTasks("bUY mIlK"), milk)
+	checkIds(t, ts.SearchTasks("école"), ecole)
+	checkIds(t, ts.SearchTasks("ÉCOLE"), ecole)
+	checkIds(t, ts.SearchTasks("ΣΟΦΊΑ"), sofia)
+	checkIds(t, ts.SearchTasks("ecole"))
+	checkIds(t, ts.SearchTasks(""), milk, ecole, sofia, 3)
+}
+
+func TestGetTasksByPriorityBreaksTiesById(t *testing.T) {
+	ts := New()
+	for _, priority := range []int{1, 3, 1, 2, 3, 1, 2, 3} {
+		if _, err := ts.CreateTaskWithPriority("task", nil, time.Time{}, priority); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The ids by priority, 3 first, each priority in id order.
+	want := []int{1, 4, 7, 3, 6, 0, 2, 5}
+
+	// Maps are iterated in random order, so a missing tie-breaker shows up over a few runs.
+	for i := 0; i < 20; i++ {
+		checkIds(t, ts.GetTasksByPriority(), want...)
+	}
+}
+
+func TestDeleteTasksByTagKeepsOtherTags(t *testing.T) {
+	ts := New()
+	mustCreate(t, ts, "a", []string{"a"}, time.Time{})
+	both := mustCreate(t, ts, "a and b", []string{"b", "a"}, time.Time{})
+	b := mustCreate(t, ts, "b", []string{"b"}, time.Time{})
+	untagged := mustCreate(t, ts, "untagged", nil, time.Time{})
+	archived := mustCreate(t, ts, "archived", []string{"a"}, time.Time{})
+	ts.ArchiveTask(archived)
+
+	if n := ts.DeleteTasksByTag("a"); n != 3 {
+		t.Errorf("DeleteTasksByTag deleted %d tasks, want 3", n)
+	}
+
+	checkIds(t, ts.GetAllTasks(), b, untagged)
+	checkIds(t, ts.GetTasksByTag("b"), b)
+	checkIds(t, ts.GetTasksByTag("a"))
+	if ts.Exists(both) || ts.Exists(archived) {
+		t.Error("a task with the tag survived")
+	}
+	checkIndexes(t, ts)
+
+	if n := ts.DeleteTasksByTag("missing"); n != 0 {
+		t.Errorf("DeleteTasksByTag of a missing tag deleted %d tasks", n)
+	}
+}
+
+func TestSearchTasksMatchesNotes(t *testing.T) {
+	ts := New()
+	inNotes, err := ts.CreateTaskFromInput(TaskInput{Text: "call the bank", Notes: "Ask about the MORTGAGE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inText := mustCreate(t, ts, "read about mortgages", nil, time.Time{})
+	mustCreate(t, ts, "unrelated", nil, time.Time{})
+
+	checkIds(t, ts.SearchTasks("mortgage"), inNotes, inText)
+	checkIds(t, ts.SearchTasks("ask about"), inNotes)
+	checkIds(t, ts.SearchTasks("bank"), inNotes)
+}
+
+func TestRenameTagDeduplicates(t *testing.T) {
+	ts := New()
+	both := mustCreate(t, ts, "both", []string{"old", "x", "new"}, time.Time{})
+	onlyOld := mustCreate(t, ts, "only old", []string{"x", "old"}, time.Time{})
+	onlyNew := mustCreate(t, ts, "only new", []string{"new"}, time.Time{})
+
+	n, err := ts.RenameTag("old", "new")
+	if err != nil || n != 2 {
+		t.Errorf("RenameTag returned (%d, %v), want (2, nil)", n, err)
+	}
+
+	for id, want := range map[int]string{both: "[x new]", onlyOld: "[x new]", onlyNew: "[new]"} {
+		if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != want {
+			t.Errorf("task %d has tags %q, want %s", id, task.Tags, want)
+		}
+	}
+
+	checkIds(t, ts.GetTasksByTag("new"), both, onlyOld, onlyNew)
+	checkIds(t, ts.GetTasksByTag("old"))
+	if counts := ts.TagCounts(); counts["new"] != 3 || len(counts) != 2 {
+		t.Errorf("tag counts are %v, want new counted once per task", counts)
+	}
+	checkIndexes(t, ts)
+}
+
+func TestGetTasksByExactTextReturnsDuplicates(t *testing.T) {
+	ts := New()
+	first := mustCreate(t, ts, "water plants", nil, time.Time{})
+	mustCreate(t, ts, "Water plants", nil, time.Time{})
+	mustCreate(t, ts, "water plants ", nil, time.Time{})
+	second := mustCreate(t, ts, "water plants", []string{"home"}, time.Time{})
+	archived := mustCreate(t, ts, "water plants", nil, time.Time{})
+	ts.ArchiveTask(archived)
+
+	checkIds(t, ts.GetTasksByExactText("water plants"), first, second)
+	checkIds(t, ts.GetTasksByExactText("water"))
+}
+
+func TestOverdueWithFrozenClock(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ts := New()
+	ts.SetClock(func() time.Time { return now })
+
+	overdue := mustCreate(t, ts, "overdue", nil, now.Add(-time.Hour))
+	mostOverdue := mustCreate(t, ts, "most overdue", nil, now.Add(-48*time.Hour))
+	done := mustCreate(t, ts, "done", nil, now.Add(-time.Hour))
+	ts.SetTaskDone(done, true)
+	dueNow := mustCreate(t, ts, "due now", nil, now)
+	mustCreate(t, ts, "no due", nil, time.Time{})
+
+	checkIds(t, ts.GetOverdueTasks(), mostOverdue, overdue, done)
+	checkIds(t, ts.GetOpenOverdueTasks(), mostOverdue, overdue)
+
+	// Moving the clock makes the task due now overdue, without changing anything else.
+	now = now.Add(time.Nanosecond)
+	checkIds(t, ts.GetOpenOverdueTasks(), mostOverdue, overdue, dueNow)
+}
+
+func TestCreateTasksAtCapacity(t *testing.T) {
+	ts := New()
+	ts.SetMaxTasks(3)
+	mustCreate(t, ts, "first", nil, time.Time{})
+
+	_, err := ts.CreateTasks([]TaskInput{{Text: "a"}, {Text: "b"}, {Text: "c"}})
+	if !errors.Is(err, ErrStoreFull) {
+		t.Errorf("CreateTasks one past the limit returned %v, want ErrStoreFull", err)
+	}
+	if n := ts.Count(); n != 1 {
+		t.Fatalf("store has %d tasks after the failed CreateTasks, want 1", n)
+	}
+
+	// A failing input anywhere in the batch creates nothing either.
+	if _, err := ts.CreateTasks([]TaskInput{{Text: "a"}, {Text: ""}}); err == nil {
+		t.Error("CreateTasks with an empty text succeeded")
+	}
+	if n := ts.Count(); n != 1 {
+		t.Fatalf("store has %d tasks after the invalid CreateTasks, want 1", n)
+	}
+
+	ids, err := ts.CreateTasks([]TaskInput{{Text: "a"}, {Text: "b"}})
+	if err != nil {
+		t.Fatalf("CreateTasks exactly up to the limit: %v", err)
+	}
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Errorf("CreateTasks returned ids %v, want [1 2]", ids)
+	}
+
+	if _, err := ts.CreateTask("full", nil, time.Time{}); !errors.Is(err, ErrStoreFull) {
+		t.Errorf("CreateTask in a full store returned %v, want ErrStoreFull", err)
+	}
+	if ids, err := ts.CreateTasks(nil); err != nil || len(ids) != 0 {
+		t.Errorf("CreateTasks of nothing in a full store returned (%v, %v), want no error", ids, err)
+	}
+}
+
+func TestGetNextDueTaskBreaksTiesById(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ts := New()
+	mustCreate(t, ts, "later", nil, now.Add(2*time.Hour))
+	var tied []int
+	for i := 0; i < 5; i++ {
+		tied = append(tied, mustCreate(t, ts, "tied", nil, now.Add(time.Hour)))
+	}
+	mustCreate(t, ts, "past", nil, now.Add(-time.Hour))
+	ts.SetTaskDone(tied[0], true)
+
+	// Maps are iterated in random order, so a missing tie-breaker shows up over a few runs.
+	for i := 0; i < 20; i++ {
+		if task, ok := ts.GetNextDueTask(now); !ok || task.Id != tied[1] {
+			t.Fatalf("GetNextDueTask returned (%d, %v), want (%d, true)", task.Id, ok, tied[1])
+		}
+	}
+
+	if _, ok := ts.GetNextDueTask(now.Add(3 * time.Hour)); ok {
+		t.Error("GetNextDueTask found a task after every due date")
+	}
+}
+
+func TestEqualContent(t *testing.T) {
+	due := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	task := Task{Id: 1, Text: "task", Tags: []string{"a", "b"}, Due: due}
+
+	tests := []struct {
+		name  string
+		other Task
+		want  bool
+	}{
+		{"reordered tags", Task{Id: 2, Text: "task", Tags: []string{"b", "a"}, Due: due, Done: true}, true},
+		{"same instant elsewhere", Task{Text: "task", Tags: []string{"a", "b"}, Due: due.In(time.FixedZone("UTC+2", 2*3600))}, true},
+		{"other text", Task{Text: "Task", Tags: []string{"a", "b"}, Due: due}, false},
+		{"missing tag", Task{Text: "task", Tags: []string{"a"}, Due: due}, false},
+		{"other due", Task{Text: "task", Tags: []string{"a", "b"}, Due: due.Add(time.Second)}, false},
+	}
+
+	for _, test := range tests {
+		if got := task.EqualContent(test.other); got != test.want {
+			t.Errorf("%s: EqualContent = %v, want %v", test.name, got, test.want)
+		}
+		if got := test.other.EqualContent(task); got != test.want {
+			t.Errorf("%s: reversed EqualContent = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if !(Task{Text: "task"}).EqualContent(Task{Text: "task", Tags: []string{}}) {
+		t.Error("nil and empty tags are not equal")
+	}
+}
+
+func TestGetTasksWithoutDue(t *testing.T) {
+	ts := New()
+	due := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	first := mustCreate(t, ts, "no due", nil, time.Time{})
+	mustCreate(t, ts, "due", nil, due)
+	second := mustCreate(t, ts, "no due either", nil, time.Time{})
+	rescheduled := mustCreate(t, ts, "due until rescheduled", nil, due)
+	ts.RescheduleTask(rescheduled, time.Time{})
+	archived := mustCreate(t, ts, "archived", nil, time.Time{})
+	ts.ArchiveTask(archived)
+
+	checkIds(t, ts.GetTasksWithoutDue(), first, second, rescheduled)
+}
+
+func TestSetDoneByTagLeavesOtherTasks(t *testing.T) {
+	ts := New()
+	tagged := mustCreate(t, ts, "tagged", []string{"home"}, time.Time{})
+	alreadyDone := mustCreate(t, ts, "already done", []string{"home"}, time.Time{})
+	ts.SetTaskDone(alreadyDone, true)
+	untagged := mustCreate(t, ts, "untagged", nil, time.Time{})
+	otherTag := mustCreate(t, ts, "other tag", []string{"work"}, time.Time{})
+
+	before := ts.Snapshot()
+
+	if n := ts.SetDoneByTag("home", true); n != 1 {
+		t.Errorf("SetDoneByTag changed %d tasks, want 1", n)
+	}
+
+	if task, _ := ts.GetTask(tagged); !task.Done {
+		t.Error("the tagged task is not done")
+	}
+	for _, id := range []int{alreadyDone, untagged, otherTag} {
+		if task, _ := ts.GetTask(id); !reflect.DeepEqual(task, before[id]) {
+			t.Errorf("task %d changed from %+v to %+v", id, before[id], task)
+		}
+	}
+}
+
+func TestGetTasksByIdRangeIsInclusive(t *testing.T) {
+	ts := New()
+	for i := 0; i < 10; i++ {
+		mustCreate(t, ts, "task", nil, time.Time{})
+	}
+	ts.ArchiveTask(5)
+
+	// The first ranges are short enough to look the ids up, the others scan the store.
+	ranges := []struct {
+		min, max int
+		want     []int
+	}{
+		{2, 4, []int{2, 3, 4}},
+		{3, 3, []int{3}},
+		{4, 6, []int{4, 6}},
+		{8, 12, []int{8, 9}},
+		{-5, 1, []int{0, 1}},
+		{0, 9, []int{0, 1, 2, 3, 4, 6, 7, 8, 9}},
+		{1, math.MaxInt, []int{1, 2, 3, 4, 6, 7, 8, 9}},
+		{math.MinInt, 0, []int{0}},
+		{10, 100, nil},
+	}
+
+	for _, r := range ranges {
+		tasks, err := ts.GetTasksByIdRange(r.min, r.max)
+		if err != nil {
+			t.Errorf("GetTasksByIdRange(%d, %d): %v", r.min, r.max, err)
+			continue
+		}
+		if got := taskIds(tasks); !equalInts(got, r.want) {
+			t.Errorf("GetTasksByIdRange(%d, %d) returned %v, want %v", r.min, r.max, got, r.want)
+		}
+	}
+
+	if _, err := ts.GetTasksByIdRange(4, 3); err == nil {
+		t.Error("GetTasksByIdRange with min greater than max succeeded")
+	}
+
+	// A short range ending at math.MaxInt must not loop forever.
+	high := NewWithStartId(math.MaxInt - 1)
+	last := mustCreate(t, high, "last", nil, time.Time{})
+	if tasks, err := high.GetTasksByIdRange(math.MaxInt-1, math.MaxInt); err != nil || len(tasks) != 1 || tasks[0].Id != last {
+		t.Errorf("range up to math.MaxInt returned (%v, %v), want the last task", taskIds(tasks), err)
+	}
+}
+
+func TestReplaceAllWithSparseIds(t *testing.T) {
+	ts := New()
+	for i := 0; i < 20; i++ {
+		mustCreate(t, ts, "task", nil, time.Time{})
+	}
+
+	if err := ts.ReplaceAll([]Task{{Id: 7, Text: "seven"}, {Id: 3, Text: "three"}, {Id: 12, Text: "twelve"}}); err != nil {
+		t.Fatal(err)
+	}
+	checkIds(t, ts.GetAllTasks(), 3, 7, 12)
+
+	// The next id moves back to just past the largest id, never into a gap.
+	if id := mustCreate(t, ts, "new", nil, time.Time{}); id != 13 {
+		t.Errorf("next task got id=%d, want 13", id)
+	}
+
+	if err := ts.ReplaceAll([]Task{{Id: 1, Text: "a"}, {Id: 1, Text: "b"}}); err == nil {
+		t.Error("ReplaceAll with duplicate ids succeeded")
+	}
+	checkIds(t, ts.GetAllTasks(), 3, 7, 12, 13)
+
+	if err := ts.ReplaceAll(nil); err != nil {
+		t.Fatal(err)
+	}
+	if id := mustCreate(t, ts, "first", nil, time.Time{}); id != 0 {
+		t.Errorf("first task after emptying got id=%d, want 0", id)
+	}
+}
+
+func TestGetTasksByTagPrefixReturnsTasksOnce(t *testing.T) {
+	ts := New()
+	both := mustCreate(t, ts, "both", []string{"project/alpha", "project/beta"}, time.Time{})
+	one := mustCreate(t, ts, "one", []string{"home", "project/alpha"}, time.Time{})
+	mustCreate(t, ts, "other", []string{"projects", "home"}, time.Time{})
+	untagged := mustCreate(t, ts, "untagged", nil, time.Time{})
+
+	checkIds(t, ts.GetTasksByTagPrefix("project/"), both, one)
+	checkIds(t, ts.GetTasksByTagPrefix("project/beta"), both)
+	checkIds(t, ts.GetTasksByTagPrefix("work"))
+	checkIds(t, ts.GetTasksByTagPrefix(""), both, one, 2, untagged)
+}
+
+func TestGetTasksByTagsExcluding(t *testing.T) {
+	ts := New()
+	kept := mustCreate(t, ts, "kept", []string{"work", "urgent"}, time.Time{})
+	both := mustCreate(t, ts, "include and exclude", []string{"work", "urgent", "blocked"}, time.Time{})
+	partial := mustCreate(t, ts, "only one include", []string{"work"}, time.Time{})
+	excludedOnly := mustCreate(t, ts, "only exclude", []string{"blocked"}, time.Time{})
+	untagged := mustCreate(t, ts, "untagged", nil, time.Time{})
+
+	checkIds(t, ts.GetTasksByTagsExcluding([]string{"work", "urgent"}, []string{"blocked"}), kept)
+	checkIds(t, ts.GetTasksByTagsExcluding([]string{"work"}, []string{"blocked"}), kept, partial)
+	checkIds(t, ts.GetTasksByTagsExcluding(nil, []string{"blocked"}), kept, partial, untagged)
+	checkIds(t, ts.GetTasksByTagsExcluding([]string{"work"}, nil), kept, both, partial)
+	checkIds(t, ts.GetTasksByTagsExcluding(nil, nil), kept, both, partial, excludedOnly, untagged)
+}
+
+func TestGetTasksDueTodayWithFrozenClock(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 9, 0, 0, 0, time.UTC)
+	ts := New()
+	ts.SetClock(func() time.Time { return now })
+
+	late := mustCreate(t, ts, "late", nil, time.Date(2023, time.March, 1, 23, 59, 0, 0, time.UTC))
+	early := mustCreate(t, ts, "early", nil, time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC))
+	mustCreate(t, ts, "yesterday", nil, time.Date(2023, time.February, 28, 23, 59, 0, 0, time.UTC))
+	tomorrow := mustCreate(t, ts, "tomorrow", nil, time.Date(2023, time.March, 2, 0, 0, 0, 0, time.UTC))
+	mustCreate(t, ts, "no due", nil, time.Time{})
+
+	checkIds(t, ts.GetTasksDueToday(), early, late)
+
+	now = now.AddDate(0, 0, 1)
+	checkIds(t, ts.GetTasksDueToday(), tomorrow)
+}
+
+func TestGetTasksByWeekday(t *testing.T) {
+	// 1 March 2023 was a Wednesday.
+	wednesday := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ts := New()
+	first := mustCreate(t, ts, "next week", nil, wednesday.AddDate(0, 0, 7))
+	second := mustCreate(t, ts, "this week", nil, wednesday)
+	thursday := mustCreate(t, ts, "thursday", nil, wednesday.AddDate(0, 0, 1))
+	// Late on Wednesday in UTC-5 is already Thursday in UTC, the local day counts.
+	local := mustCreate(t, ts, "late elsewhere", nil, time.Date(2023, time.March, 1, 22, 0, 0, 0, time.FixedZone("UTC-5", -5*3600)))
+	mustCreate(t, ts, "no due", nil, time.Time{})
+
+	checkIds(t, ts.GetTasksByWeekday(time.Wednesday), second, local, first)
+	checkIds(t, ts.GetTasksByWeekday(time.Thursday), thursday)
+	checkIds(t, ts.GetTasksByWeekday(time.Sunday))
+}
+
+func TestFilter(t *testing.T) {
+	ts := New()
+	high, _ := ts.CreateTaskWithPriority("high", []string{"a"}, time.Time{}, 3)
+	low, _ := ts.CreateTaskWithPriority("low", []string{"a"}, time.Time{}, 1)
+	done, _ := ts.CreateTaskWithPriority("done", nil, time.Time{}, 3)
+	ts.SetTaskDone(done, true)
+	archived, _ := ts.CreateTaskWithPriority("archived", nil, time.Time{}, 3)
+	ts.ArchiveTask(archived)
+
+	checkIds(t, ts.Filter(func(task Task) bool { return task.Priority >= 3 }), high, done)
+	checkIds(t, ts.Filter(func(task Task) bool { return task.Priority >= 3 && !task.Done }), high)
+	checkIds(t, ts.Filter(func(task Task) bool { return len(task.Tags) > 0 }), high, low)
+	checkIds(t, ts.Filter(func(Task) bool { return true }), high, low, done)
+	checkIds(t, ts.Filter(func(Task) bool { return false }))
+
+	// The predicate cannot change the tasks it is given.
+	ts.Filter(func(task Task) bool {
+		if len(task.Tags) > 0 {
+			task.Tags[0] = "changed"
+		}
+		return true
+	})
+	checkIds(t, ts.GetTasksByTag("a"), high, low)
+	if task, _ := ts.GetTask(high); task.Tags[0] != "a" {
+		t.Errorf("the predicate changed the tags to %q", task.Tags)
+	}
+}
+
+func TestPatchTasksWithMissingIds(t *testing.T) {
+	ts := New()
+	a := mustCreate(t, ts, "a", nil, time.Time{})
+	b := mustCreate(t, ts, "b", nil, time.Time{})
+	untouched := mustCreate(t, ts, "untouched", nil, time.Time{})
+
+	priority := 2
+	patched, missing, err := ts.PatchTasks([]int{a, 42, b, a, -1}, TaskUpdate{Priority: &priority})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched != 2 || fmt.Sprint(missing) != "[42 -1]" {
+		t.Errorf("PatchTasks returned (%d, %v), want (2, [42 -1])", patched, missing)
+	}
+
+	for id, want := range map[int]int{a: 2, b: 2, untouched: 0} {
+		if task, _ := ts.GetTask(id); task.Priority != want {
+			t.Errorf("task %d has priority %d, want %d", id, task.Priority, want)
+		}
+	}
+	if task, _ := ts.GetTask(a); task.Version != 2 {
+		t.Errorf("task given twice has version %d, want 2", task.Version)
+	}
+
+	// An invalid update patches nothing.
+	empty := ""
+	if _, _, err := ts.PatchTasks([]int{a, 42}, TaskUpdate{Text: &empty}); err == nil {
+		t.Error("PatchTasks with an empty text succeeded")
+	}
+	if task, _ := ts.GetTask(a); task.Text != "a" {
+		t.Errorf("failed PatchTasks changed the text to %q", task.Text)
+	}
+}
+
+func TestSearchByTagsRanked(t *testing.T) {
+	ts := New()
+	one := mustCreate(t, ts, "one", []string{"a", "x"}, time.Time{})
+	three := mustCreate(t, ts, "three", []string{"c", "b", "a"}, time.Time{})
+	two := mustCreate(t, ts, "two", []string{"b", "x", "c"}, time.Time{})
+	otherOne := mustCreate(t, ts, "other one", []string{"c"}, time.Time{})
+	mustCreate(t, ts, "none", []string{"x"}, time.Time{})
+	archived := mustCreate(t, ts, "archived", []string{"a", "b", "c"}, time.Time{})
+	ts.ArchiveTask(archived)
+
+	checkIds(t, ts.SearchByTagsRanked([]string{"a", "b", "c"}), three, two, one, otherOne)
+	// Repeating a query tag does not count it twice.
+	checkIds(t, ts.SearchByTagsRanked([]string{"c", "c", "a"}), three, one, two, otherOne)
+	checkIds(t, ts.SearchByTagsRanked(nil))
+}
+
+func TestTouchOnlyBumpsVersionAndTime(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ts := New()
+	ts.SetClock(func() time.Time { return now })
+
+	id := mustCreate(t, ts, "task", []string{"a"}, now.AddDate(0, 0, 1))
+	before, _ := ts.GetTask(id)
+
+	now = now.Add(time.Minute)
+	if err := ts.Touch(id); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := ts.GetTask(id)
+
+	if after.Version != before.Version+1 || !after.UpdatedAt.Equal(now) {
+		t.Errorf("Touch left version %d and time %v, want %d and %v", after.Version, after.UpdatedAt, before.Version+1, now)
+	}
+
+	after.Version, after.UpdatedAt = before.Version, before.UpdatedAt
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("Touch changed the task from %+v to %+v", before, after)
+	}
+	checkIds(t, ts.GetTasksModifiedSince(before.UpdatedAt), id)
+
+	if err := ts.Touch(-1); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Touch on a missing id returned %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestGetTasksAfterWhileDeleting(t *testing.T) {
+	ts := New()
+	for i := 0; i < 7; i++ {
+		mustCreate(t, ts, "task", nil, time.Time{})
+	}
+
+	var seen []int
+	cursor := -1
+	for page := 0; ; page++ {
+		tasks, next, err := ts.GetTasksAfter(cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) == 0 {
+			if next != cursor {
+				t.Errorf("empty page moved the cursor from %d to %d", cursor, next)
+			}
+			break
+		}
+		seen = append(seen, taskIds(tasks)...)
+		cursor = next
+
+		// Deleting a task that was already returned does not shift the next pages, nor
+		// does creating one after the cursor.
+		if page == 0 {
+			ts.DeleteTask(tasks[0].Id)
+			mustCreate(t, ts, "new", nil, time.Time{})
+		}
+	}
+
+	if want := []int{0, 1, 2, 3, 4, 5, 6, 7}; !equalInts(seen, want) {
+		t.Errorf("paging returned %v, want %v", seen, want)
+	}
+
+	if _, _, err := ts.GetTasksAfter(-1, 0); err == nil {
+		t.Error("GetTasksAfter with a limit of 0 succeeded")
+	}
+}
+
+func TestWithLockSwapsAtomically(t *testing.T) {
+	ts := New()
+	a := mustCreate(t, ts, "a", nil, time.Time{})
+	b := mustCreate(t, ts, "b", nil, time.Time{})
+	other := mustCreate(t, ts, "other", nil, time.Time{})
+
+	const swaps = 200
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				tasks, _ := ts.GetTasks([]int{a, b})
+				if len(tasks) != 2 || tasks[0].Text == tasks[1].Text {
+					t.Errorf("read the tasks halfway through a swap: %+v", tasks)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < swaps; i++ {
+		ts.WithLock(func(tasks map[int]Task) {
+			first, second := tasks[a], tasks[b]
+			first.Text, second.Text = second.Text, first.Text
+			tasks[a], tasks[b] = first, second
+		})
+	}
+	close(stop)
+	wg.Wait()
+
+	first, _ := ts.GetTask(a)
+	second, _ := ts.GetTask(b)
+	if first.Text != "a" || second.Text != "b" {
+		t.Errorf("after %d swaps the texts are %q and %q, want a and b", swaps, first.Text, second.Text)
+	}
+	if first.Version != swaps+1 || second.Version != swaps+1 {
+		t.Errorf("after %d swaps the versions are %d and %d, want %d", swaps, first.Version, second.Version, swaps+1)
+	}
+	if task, _ := ts.GetTask(other); task.Version != 1 {
+		t.Errorf("the task left alone has version %d, want 1", task.Version)
+	}
+}
+
+func TestWithLockAppliesOnlyChanges(t *testing.T) {
+	ts := New()
+	kept := mustCreate(t, ts, "kept", []string{"a"}, time.Time{})
+	changed := mustCreate(t, ts, "changed", nil, time.Time{})
+	deleted := mustCreate(t, ts, "deleted", nil, time.Time{})
+
+	events, unsubscribe := ts.Subscribe()
+	defer unsubscribe()
+
+	ts.WithLock(func(tasks map[int]Task) {})
+	select {
+	case event := <-events:
+		t.Errorf("WithLock changing nothing emitted %+v", event)
+	default:
+	}
+
+	ts.WithLock(func(tasks map[int]Task) {
+		task := tasks[changed]
+		task.Tags = []string{"b"}
+		tasks[changed] = task
+		delete(tasks, deleted)
+		tasks[10] = Task{Text: "added"}
+	})
+
+	var got []TaskEvent
+	for len(events) > 0 {
+		got = append(got, <-events)
+	}
+	want := []TaskEvent{{EventDeleted, deleted}, {EventUpdated, changed}, {EventCreated, 10}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("WithLock emitted %v, want %v", got, want)
+	}
+
+	if task, _ := ts.GetTask(kept); task.Version != 1 {
+		t.Errorf("the task left alone has version %d, want 1", task.Version)
+	}
+	checkIds(t, ts.GetTasksByTag("b"), changed)
+	checkIndexes(t, ts)
+	if id := mustCreate(t, ts, "next", nil, time.Time{}); id != 11 {
+		t.Errorf("next task got id=%d, want 11", id)
+	}
+}
+
+func TestUpdateTaskNotes(t *testing.T) {
+	ts := New()
+	id, err := ts.CreateTaskFromInput(TaskInput{Text: "task", Notes: "first notes", Priority: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// UpdateTask keeps the notes.
+	ts.UpdateTask(id, "edited", nil, time.Time{})
+	if task, _ := ts.GetTask(id); task.Notes != "first notes" {
+		t.Errorf("notes after UpdateTask are %q, want them kept", task.Notes)
+	}
+
+	if err := ts.UpdateTaskFromInput(id, TaskInput{Text: "edited", Notes: "second notes", Tags: []string{" a "}}); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := ts.GetTask(id); task.Notes != "second notes" || task.Priority != 0 || fmt.Sprint(task.Tags) != "[a]" {
+		t.Errorf("task after UpdateTaskFromInput is %+v", task)
+	}
+	checkIds(t, ts.SearchTasks("second"), id)
+
+	if err := ts.UpdateTaskFromInput(id, TaskInput{Text: "edited"}); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := ts.GetTask(id); task.Notes != "" {
+		t.Errorf("notes after clearing them are %q", task.Notes)
+	}
+
+	notes := "patched notes"
+	if task, err := ts.PatchTask(id, TaskUpdate{Notes: &notes}); err != nil || task.Notes != notes {
+		t.Errorf("PatchTask returned (%+v, %v), want the patched notes", task, err)
+	}
+
+	if err := ts.UpdateTaskFromInput(id, TaskInput{}); !errors.Is(err, ErrEmptyText) {
+		t.Errorf("UpdateTaskFromInput without text returned %v, want ErrEmptyText", err)
+	}
+	if err := ts.UpdateTaskFromInput(-1, TaskInput{Text: "task"}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("UpdateTaskFromInput on a missing id returned %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestSearchByPrefixSortsIgnoringCase(t *testing.T) {
+	ts := New()
+	zebra := mustCreate(t, ts, "Zebra", nil, time.Time{})
+	apple := mustCreate(t, ts, "apple", nil, time.Time{})
+	upperApple := mustCreate(t, ts, "APPLE", nil, time.Time{})
+	banana := mustCreate(t, ts, "Banana", nil, time.Time{})
+	applePie := mustCreate(t, ts, "Apple pie", nil, time.Time{})
+
+	checkIds(t, ts.SearchByPrefix(""), apple, upperApple, applePie, banana, zebra)
+	checkIds(t, ts.SearchByPrefix("aPp"), apple, upperApple, applePie)
+	checkIds(t, ts.SearchByPrefix("z"), zebra)
+}
+
+func TestUpdateTaskIfVersionConflict(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "original", nil, time.Time{})
+	stale, _ := ts.GetTask(id)
+
+	first := "first"
+	updated, err := ts.UpdateTaskIfVersion(id, stale.Version, TaskUpdate{Text: &first})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != stale.Version+1 {
+		t.Errorf("version after the update is %d, want %d", updated.Version, stale.Version+1)
+	}
+
+	second := "second"
+	if _, err := ts.UpdateTaskIfVersion(id, stale.Version, TaskUpdate{Text: &second}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("update with a stale version returned %v, want ErrVersionConflict", err)
+	}
+
+	if task, _ := ts.GetTask(id); task.Text != "first" || task.Version != updated.Version {
+		t.Errorf("after the conflict the task has text %q and version %d, want %q and %d", task.Text, task.Version, "first", updated.Version)
+	}
+
+	if _, err := ts.UpdateTaskIfVersion(-1, 1, TaskUpdate{Text: &second}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("update of a missing id returned %v, want ErrTaskNotFound", err)
+	}
+}
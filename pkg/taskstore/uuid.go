@@ -0,0 +1,40 @@
+package taskstore
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// NewWithUUID creates a new store that also gives every created task a random UUID, stored
+// in its Uid field, which can be used to look the task up with GetTaskByUid.
+func NewWithUUID() *TaskStore {
+	ts := New()
+	ts.useUUID = true
+	return ts
+}
+
+// GetTaskByUid retrieves a task from the store, by UUID. If no such UUID exists, an error is
+// returned.
+func (ts *TaskStore) GetTaskByUid(uid string) (Task, error) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if id, ok := ts.uids[uid]; ok {
+		return copyTask(ts.task(id)), nil
+	}
+
+	return Task{}, fmt.Errorf("task with uid=%s does not exist: %w", uid, ErrTaskNotFound)
+}
+
+// newUUID returns a random version 4 UUID as defined by RFC 4122.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("taskstore: cannot generate uuid: %v", err))
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,136 @@
+package taskstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The validation errors, they are returned wrapped in an error telling the offending value.
+var (
+	// ErrEmptyText means the text of the task is empty or only made of white space.
+	ErrEmptyText = errors.New("empty task text")
+	// ErrEmptyTag means one of the tags of the task is empty or only made of white space.
+	ErrEmptyTag = errors.New("empty tag")
+	// ErrInvalidTag means one of the tags of the task contains a comma or a semicolon, which
+	// separate the tags in URLs and in the CSV export.
+	ErrInvalidTag = errors.New("invalid tag")
+	// ErrDueOutOfRange means the due date is outside the years 1 to 9999, which cannot be
+	// represented in JSON.
+	ErrDueOutOfRange = errors.New("due date out of range")
+	// ErrTooManyTags means the task has more tags than allowed by SetMaxTags.
+	ErrTooManyTags = errors.New("too many tags")
+	// ErrDueTooOld means the due date is further in the past than allowed by
+	// SetMaxDueYearsInPast.
+	ErrDueTooOld = errors.New("due date too far in the past")
+)
+
+// ValidateTask checks the text, tags and due date of a task against the rules that apply to
+// every store, the returned error wraps one of the validation errors. Stores also enforce
+// the limits they are configured with, see SetMaxTags and SetMaxDueYearsInPast.
+func ValidateTask(text string, tags []string, due time.Time) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("task text %q: %w", text, ErrEmptyText)
+	}
+
+	for i, tag := range tags {
+		if err := ValidateTag(tag); err != nil {
+			return fmt.Errorf("tag %d: %w", i, err)
+		}
+	}
+
+	if !due.IsZero() && (due.Year() < 1 || due.Year() > 9999) {
+		return fmt.Errorf("task due date %v: %w", due, ErrDueOutOfRange)
+	}
+
+	return nil
+}
+
+// ValidateTag checks a single tag, the returned error wraps ErrEmptyTag or ErrInvalidTag.
+// White space around the tag is allowed, the store trims it when the tag is added.
+func ValidateTag(tag string) error {
+	if strings.TrimSpace(tag) == "" {
+		return fmt.Errorf("tag %q: %w", tag, ErrEmptyTag)
+	}
+
+	if strings.ContainsAny(tag, ",;") {
+		return fmt.Errorf("tag %q: %w", tag, ErrInvalidTag)
+	}
+
+	return nil
+}
+
+// trimTags returns a copy of tags with the white space around every tag removed.
+func trimTags(tags []string) []string {
+	trimmed := make([]string, len(tags))
+
+	for i, tag := range tags {
+		trimmed[i] = strings.TrimSpace(tag)
+	}
+
+	return trimmed
+}
+
+// SetMaxDueYearsInPast makes the store reject tasks due more than the given number of years
+// in the past. A value of 0, the default, disables the check.
+func (ts *TaskStore) SetMaxDueYearsInPast(years int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.maxDueYearsInPast = years
+}
+
+// SetMaxTags makes the store reject tasks with more than n tags. A value of 0, the default,
+// means there is no limit.
+func (ts *TaskStore) SetMaxTags(n int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.maxTags = n
+}
+
+// SetMaxTasks limits the number of tasks held by the store, archived ones included, to n.
+// Creating tasks past the limit fails with an error wrapping ErrStoreFull. A value of 0, the
+// default, means there is no limit.
+func (ts *TaskStore) SetMaxTasks(n int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.maxTasks = n
+}
+
+// ValidateOnly returns the error CreateTask would return for the same arguments, without
+// creating anything. It only takes the read lock, and the answer may be stale by the time
+// CreateTask is called.
+func (ts *TaskStore) ValidateOnly(text string, tags []string, due time.Time) error {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if err := ts.validate(text, tags, due); err != nil {
+		return err
+	}
+
+	return ts.checkCapacity(1)
+}
+
+// validate checks the text, tags and due date of a task about to be stored against
+// ValidateTask and the limits of the store, the caller must hold the lock.
+func (ts *TaskStore) validate(text string, tags []string, due time.Time) error {
+	if err := ValidateTask(text, tags, due); err != nil {
+		return err
+	}
+
+	if ts.maxTags > 0 && len(tags) > ts.maxTags {
+		return fmt.Errorf("task has %d tags, the maximum is %d: %w", len(tags), ts.maxTags, ErrTooManyTags)
+	}
+
+	if ts.maxDueYearsInPast > 0 && !due.IsZero() {
+		limit := ts.now().AddDate(-ts.maxDueYearsInPast, 0, 0)
+		if due.Before(limit) {
+			return fmt.Errorf("task due date %v is more than %d years in the past: %w", due, ts.maxDueYearsInPast, ErrDueTooOld)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package taskstore
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestValidateTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want error
+	}{
+		{"urgent", nil},
+		{"  urgent ", nil},
+		{"project/alpha", nil},
+		{"", ErrEmptyTag},
+		{"   ", ErrEmptyTag},
+		{"\t\n", ErrEmptyTag},
+		{"a,b", ErrInvalidTag},
+		{"a;b", ErrInvalidTag},
+	}
+
+	for _, test := range tests {
+		if err := ValidateTag(test.tag); !errors.Is(err, test.want) || (test.want == nil && err != nil) {
+			t.Errorf("ValidateTag(%q) = %v, want %v", test.tag, err, test.want)
+		}
+	}
+}
+
+func TestTagsAreTrimmed(t *testing.T) {
+	ts := New()
+	id := mustCreate(t, ts, "task", []string{"  urgent "}, time.Time{})
+
+	if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[urgent]" {
+		t.Errorf("CreateTask stored the tags %q, want [urgent]", task.Tags)
+	}
+
+	if err := ts.ReplaceTags(id, []string{" home", "home "}); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[home]" {
+		t.Errorf("ReplaceTags stored the tags %q, want [home]", task.Tags)
+	}
+
+	if n, err := ts.RenameTag("home", "  work "); n != 1 || err != nil {
+		t.Fatalf("RenameTag returned (%d, %v), want (1, nil)", n, err)
+	}
+	if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[work]" {
+		t.Errorf("RenameTag stored the tags %q, want [work]", task.Tags)
+	}
+}
+
+func TestBadTagsAreRejected(t *testing.T) {
+	ts := New()
+
+	if _, err := ts.CreateTask("task", []string{" "}, time.Time{}); !errors.Is(err, ErrEmptyTag) {
+		t.Errorf("CreateTask with a blank tag returned %v, want ErrEmptyTag", err)
+	}
+
+	id := mustCreate(t, ts, "task", []string{"x"}, time.Time{})
+
+	if err := ts.AddTagToTask(id, "a,b"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("AddTagToTask with a comma returned %v, want ErrInvalidTag", err)
+	}
+	if err := ts.ReplaceTags(id, []string{""}); !errors.Is(err, ErrEmptyTag) {
+		t.Errorf("ReplaceTags with an empty tag returned %v, want ErrEmptyTag", err)
+	}
+	if _, err := ts.RenameTag("x", " bad,tag "); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("RenameTag to a tag with a comma returned %v, want ErrInvalidTag", err)
+	}
+	if _, err := ts.RenameTag("x", ""); !errors.Is(err, ErrEmptyTag) {
+		t.Errorf("RenameTag to an empty tag returned %v, want ErrEmptyTag", err)
+	}
+
+	if task, _ := ts.GetTask(id); fmt.Sprint(task.Tags) != "[x]" {
+		t.Errorf("rejected changes left the tags %q, want [x]", task.Tags)
+	}
+}
+
+func TestSetMaxTags(t *testing.T) {
+	ts := New()
+	ts.SetMaxTags(2)
+
+	id := mustCreate(t, ts, "at limit", []string{"a", "b"}, time.Time{})
+	if _, err := ts.CreateTask("over limit", []string{"a", "b", "c"}, time.Time{}); !errors.Is(err, ErrTooManyTags) {
+		t.Errorf("CreateTask over the limit returned %v, want ErrTooManyTags", err)
+	}
+
+	if err := ts.AddTagToTask(id, "c"); !errors.Is(err, ErrTooManyTags) {
+		t.Errorf("AddTagToTask over the limit returned %v, want ErrTooManyTags", err)
+	}
+	// Adding a tag the task already has does not grow it.
+	if err := ts.AddTagToTask(id, "a"); err != nil {
+		t.Errorf("AddTagToTask of a present tag at the limit returned %v", err)
+	}
+
+	other := mustCreate(t, ts, "below limit", []string{"a"}, time.Time{})
+	if err := ts.AddTagToTask(other, "b"); err != nil {
+		t.Errorf("AddTagToTask up to the limit returned %v", err)
+	}
+	if task, _ := ts.GetTask(other); fmt.Sprint(task.Tags) != "[a b]" {
+		t.Errorf("tags are %q, want [a b]", task.Tags)
+	}
+
+	ts.SetMaxTags(0)
+	if err := ts.AddTagToTask(id, "c"); err != nil {
+		t.Errorf("AddTagToTask without a limit returned %v", err)
+	}
+}
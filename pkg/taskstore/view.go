@@ -0,0 +1,31 @@
+package taskstore
+
+// SnapshotView returns the tasks that are not archived, sorted by id, like GetAllTasks, but
+// without copying them: the slice is built once after each change to the store and then
+// shared by all the callers, which read it without taking the lock. The returned slice and
+// the tags of its tasks must therefore never be modified. It is meant for hot read paths
+// where the store is read much more often than it is changed.
+func (ts *TaskStore) SnapshotView() []Task {
+	if view, _ := ts.view.Load().([]Task); view != nil {
+		return view
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	// Another reader may have built the view while waiting for the lock.
+	if view, _ := ts.view.Load().([]Task); view != nil {
+		return view
+	}
+
+	view := ts.activeTasks()
+	ts.view.Store(view)
+
+	return view
+}
+
+// invalidateView drops the view returned by SnapshotView, so it is rebuilt on the next
+// call, the caller must hold the lock.
+func (ts *TaskStore) invalidateView() {
+	ts.view.Store([]Task(nil))
+}
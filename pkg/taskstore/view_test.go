@@ -0,0 +1,67 @@
+package taskstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSnapshotViewFollowsChanges(t *testing.T) {
+	ts := New()
+	first := mustCreate(t, ts, "first", nil, time.Time{})
+	checkIds(t, ts.SnapshotView(), first)
+
+	second := mustCreate(t, ts, "second", nil, time.Time{})
+	checkIds(t, ts.SnapshotView(), first, second)
+
+	ts.DeleteTask(first)
+	checkIds(t, ts.SnapshotView(), second)
+}
+
+// benchmarkReadsUnderLoad runs read in parallel while another goroutine keeps changing a
+// task of the store, once every writeEvery.
+func benchmarkReadsUnderLoad(b *testing.B, read func(ts *TaskStore) []Task) {
+	for _, writeEvery := range []time.Duration{time.Millisecond, 100 * time.Microsecond} {
+		b.Run(fmt.Sprintf("writeEvery=%v", writeEvery), func(b *testing.B) {
+			ts := New()
+			for i := 0; i < 1000; i++ {
+				mustCreate(b, ts, fmt.Sprintf("task %d", i), []string{"tag"}, time.Time{})
+			}
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(writeEvery)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+						ts.Touch(0)
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					read(ts)
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			<-done
+		})
+	}
+}
+
+func BenchmarkSnapshotView(b *testing.B) {
+	benchmarkReadsUnderLoad(b, (*TaskStore).SnapshotView)
+}
+
+func BenchmarkGetAllTasks(b *testing.B) {
+	benchmarkReadsUnderLoad(b, (*TaskStore).GetAllTasks)
+}